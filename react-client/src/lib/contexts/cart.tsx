@@ -81,6 +81,8 @@ export function CartProvider({ children }: { children: React.ReactNode }) {
 							...variant,
 							price: undefined,
 							discountPercentage: undefined,
+							stock: undefined,
+							imageKeys: undefined,
 						})
 				)
 			})
@@ -96,6 +98,8 @@ export function CartProvider({ children }: { children: React.ReactNode }) {
 						...variant,
 						price: undefined!,
 						discountPercentage: undefined!,
+						stock: undefined!,
+						imageKeys: undefined!,
 					},
 				}
 
@@ -125,6 +129,8 @@ export function CartProvider({ children }: { children: React.ReactNode }) {
 					...variant,
 					price: undefined!,
 					discountPercentage: undefined!,
+					stock: undefined!,
+					imageKeys: undefined!,
 				},
 			})
 		} else {
@@ -182,6 +188,8 @@ export function CartProvider({ children }: { children: React.ReactNode }) {
 							...variant,
 							price: undefined,
 							discountPercentage: undefined,
+							stock: undefined,
+							imageKeys: undefined,
 						})
 				)
 			})
@@ -212,6 +220,8 @@ export function CartProvider({ children }: { children: React.ReactNode }) {
 					...variant,
 					price: undefined!,
 					discountPercentage: undefined!,
+					stock: undefined!,
+					imageKeys: undefined!,
 				},
 			})
 		} else {
@@ -17,6 +17,8 @@ export type Product = {
 	description: string
 	price: number | null
 	discountPercentage: number | null
+	stock: number
+	imageKeys: string[]
 	variants: (Record<string, string | number> & {
 		price: number
 		discountPercentage?: number
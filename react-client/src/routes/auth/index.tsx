@@ -1,5 +1,6 @@
 import { createFileRoute, useNavigate } from "@tanstack/react-router"
 import Github from "@/assets/github.svg"
+import Google from "@/assets/google.svg"
 import ArrowRight from "@/assets/arrow-right.svg"
 import ArrowLeft from "@/assets/arrow-left.svg"
 import { useToast } from "@/lib/ui/use-toast"
@@ -14,7 +15,7 @@ import { useUser } from "@/hooks/userUser"
 
 type Auth = { hasStarted: boolean; step: null | 1 | 2 }
 type Form = { email: string }
-type MutationResponse = { error: string } | { tokenId: number }
+type MutationResponse = { error: string } | { tokenId: string; nonce: string }
 
 export const Route = createFileRoute("/auth/")({
 	component: Component,
@@ -107,14 +108,23 @@ function Component() {
 					<button
 						className="flex items-center justify-center gap-3 border px-7 py-3 capitalize hover:bg-gray-50"
 						onClick={() => {
-							window.location.href =
-								"https://github.com/login/oauth/authorize?client_id=Iv1.f2c8810c117fd3d0"
+							window.location.href = `${SERVER_URL}/user/oauth/github`
 						}}
 					>
 						<img src={Github} className="w-5" />
 						<span>continue with github</span>
 					</button>
 
+					<button
+						className="flex items-center justify-center gap-3 border px-7 py-3 capitalize hover:bg-gray-50"
+						onClick={() => {
+							window.location.href = `${SERVER_URL}/user/oauth/google`
+						}}
+					>
+						<img src={Google} className="w-5" />
+						<span>continue with google</span>
+					</button>
+
 					<button
 						onClick={auth.start}
 						className="mt-5 flex items-center justify-center gap-3 px-7 py-3 font-semibold capitalize text-primary hover:bg-gray-50"
@@ -170,8 +180,10 @@ function useMagicLink() {
 		queryKey: ["check-magic-token", data] as const,
 		queryFn: async ({ queryKey }) => {
 			const data = queryKey[1]
-			const tokenId = data && "tokenId" in data ? data.tokenId : null
-			if (!tokenId) throw new Error("Token not found")
+			if (!data || !("tokenId" in data)) {
+				throw new Error("Token not found")
+			}
+			const { tokenId, nonce } = data
 
 			const res = await fetch(
 				`${SERVER_URL}/user/check-registered-magic-token`,
@@ -179,7 +191,7 @@ function useMagicLink() {
 					method: "POST",
 					headers: { "Content-Type": "application/json" },
 					credentials: "include",
-					body: JSON.stringify({ tokenId }),
+					body: JSON.stringify({ tokenId, nonce }),
 				}
 			)
 
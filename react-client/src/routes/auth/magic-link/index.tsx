@@ -21,9 +21,12 @@ export const Route = createFileRoute("/auth/magic-link/")({
 						body: JSON.stringify({ token }),
 					}
 				)
-				if (!res.ok) throw new Error("Something went wrong")
+				if (res.status >= 500) throw new Error("Something went wrong")
 
-				return res.json() as Promise<{ isTokenVerified: boolean }>
+				return res.json() as Promise<{
+					isTokenVerified?: boolean
+					error?: string
+				}>
 			},
 		})
 	},
@@ -32,15 +35,17 @@ export const Route = createFileRoute("/auth/magic-link/")({
 })
 
 function Component() {
-	const { isTokenVerified } = Route.useLoaderData()
+	const data = Route.useLoaderData()
 
-	if (isTokenVerified) {
+	if (data.isTokenVerified) {
 		return <p>Authentication successful. You can now close this tab.</p>
 	} else {
 		return (
 			<p>
-				Authentication failed. Your token may have expired or is
-				incorrect.
+				Authentication failed.{" "}
+				{"error" in data && data.error
+					? `The ${data.error}.`
+					: "Your token may have expired or is incorrect."}
 			</p>
 		)
 	}
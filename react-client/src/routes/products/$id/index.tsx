@@ -40,7 +40,8 @@ function parseVariants(variants: Product["variants"]) {
 	if (variants.length === 0) return parsedVariants
 
 	const variantNames = Object.keys(variants[0]).filter(
-		(key) => !["price", "discountPercentage"].includes(key)
+		(key) =>
+			!["price", "discountPercentage", "stock", "imageKeys"].includes(key)
 	)
 
 	variantNames.forEach((vName) => {
@@ -72,6 +73,8 @@ function getProductInfo(
 				...v,
 				price: undefined,
 				discountPercentage: undefined,
+				stock: undefined,
+				imageKeys: undefined,
 			}) === JSON.stringify(selectedVariant)
 		)
 	})[0]
@@ -164,6 +167,8 @@ function ProductActions(props: ProductActionProps) {
 				...variants[0],
 				price: undefined!,
 				discountPercentage: undefined!,
+				stock: undefined!,
+				imageKeys: undefined!,
 			}
 		}
 		return {}
@@ -304,6 +309,8 @@ function AddToCart(props: AddToCartProps) {
 						...v,
 						price: undefined,
 						discountPercentage: undefined,
+						stock: undefined,
+						imageKeys: undefined,
 					}) === JSON.stringify(selectedVariant)
 			)[0]
 
@@ -327,6 +334,8 @@ function AddToCart(props: AddToCartProps) {
 										...v,
 										price: undefined,
 										discountPercentage: undefined,
+										stock: undefined,
+										imageKeys: undefined,
 									}) === JSON.stringify(selectedVariant)
 							)[0]
 
@@ -350,6 +359,8 @@ function AddToCart(props: AddToCartProps) {
 										...v,
 										price: undefined,
 										discountPercentage: undefined,
+										stock: undefined,
+										imageKeys: undefined,
 									}) === JSON.stringify(selectedVariant)
 							)[0]
 
@@ -2,15 +2,47 @@ import { useUser } from "@/hooks/userUser"
 import { PageLayout } from "@/lib/modules/layouts"
 import { Button } from "@/lib/ui/button"
 import { Input } from "@/lib/ui/input"
+import { useToast } from "@/lib/ui/use-toast"
 import { SERVER_URL } from "@/utils/constants"
-import { queryOptions, useSuspenseQuery } from "@tanstack/react-query"
+import {
+	queryOptions,
+	useMutation,
+	useQuery,
+	useSuspenseQuery,
+} from "@tanstack/react-query"
 import { createFileRoute, redirect, useNavigate } from "@tanstack/react-router"
+import { queryClient } from "@/main"
 
 type RouteData = {
 	name: string | null
 	email: string
 }
 
+type Identity = {
+	id: number
+	provider: string
+	email: string | null
+	createdAt: string
+	lastUsedAt: string
+}
+
+type IdentitiesData = {
+	email: string
+	identities: Identity[]
+}
+
+type Session = {
+	id: string
+	ip: string | null
+	userAgent: string | null
+	createdAt: string
+	lastSeenAt: string
+	expiresAt: string
+	current: boolean
+}
+
+const PROVIDERS = ["github", "google"]
+
 const routeQueryOptions = queryOptions({
 	queryKey: ["profile"],
 	queryFn: async () => {
@@ -45,6 +77,22 @@ function Component() {
 	const { data } = useSuspenseQuery(routeQueryOptions)
 	const navigate = useNavigate()
 	const { destroySession } = useUser()
+	const { toast } = useToast()
+	const { error, success } = Route.useSearch({
+		select: (params) => ({
+			error: "error" in params ? (params.error as string) : "",
+			success: "success" in params ? (params.success as string) : "",
+		}),
+	})
+
+	if (error || success) {
+		toast({
+			title: error || success,
+			variant: error ? "destructive" : undefined,
+		})
+		navigate({ search: {}, to: "/profile", replace: true })
+	}
+
 	async function logout() {
 		await fetch(`${SERVER_URL}/user/logout`, {
 			credentials: "include",
@@ -67,9 +115,183 @@ function Component() {
 			/>
 			<Input value={data.email} disabled />
 			<Button disabled>Update</Button>
+			<LoginMethods />
+			<Sessions />
 			<Button className="bg-red-500" onClick={logout}>
 				Logout
 			</Button>
 		</div>
 	)
 }
+
+function LoginMethods() {
+	const { data } = useQuery({
+		queryKey: ["identities"],
+		queryFn: async () => {
+			const res = await fetch(`${SERVER_URL}/user/identities`, {
+				credentials: "include",
+			})
+			if (!res.ok) {
+				throw new Error("Something went wrong")
+			}
+
+			return res.json() as Promise<IdentitiesData>
+		},
+	})
+
+	const { mutate: unlink, isPending } = useMutation({
+		mutationFn: async (identityId: number) => {
+			const res = await fetch(`${SERVER_URL}/user/identities/unlink`, {
+				method: "POST",
+				headers: { "Content-Type": "application/json" },
+				credentials: "include",
+				body: JSON.stringify({ identityId }),
+			})
+			if (!res.ok) {
+				throw new Error("Something went wrong")
+			}
+		},
+		onSettled: () => {
+			queryClient.invalidateQueries({ queryKey: ["identities"] })
+		},
+	})
+
+	if (!data) return null
+
+	return (
+		<div className="mt-5 flex flex-col gap-3">
+			<p className="text-xl font-medium capitalize">login methods</p>
+			<div className="flex items-center justify-between text-sm">
+				<span>Email link to {data.email}</span>
+			</div>
+			{data.identities.map((identity) => (
+				<div
+					key={identity.id}
+					className="flex items-center justify-between text-sm"
+				>
+					<span className="capitalize">
+						{identity.provider}
+						{identity.email ? (
+							<span className="normal-case text-gray-500">
+								{" "}
+								({identity.email})
+							</span>
+						) : null}
+					</span>
+					<Button
+						size="sm"
+						variant="ghost"
+						disabled={isPending}
+						onClick={() => unlink(identity.id)}
+					>
+						Unlink
+					</Button>
+				</div>
+			))}
+			<div className="flex gap-2">
+				{PROVIDERS.map((provider) => (
+					<Button
+						key={provider}
+						size="sm"
+						variant="ghost"
+						className="capitalize"
+						onClick={() => {
+							window.location.href = `${SERVER_URL}/user/identities/${provider}/link`
+						}}
+					>
+						Link {provider}
+					</Button>
+				))}
+			</div>
+		</div>
+	)
+}
+
+function Sessions() {
+	const { data } = useQuery({
+		queryKey: ["sessions"],
+		queryFn: async () => {
+			const res = await fetch(`${SERVER_URL}/user/sessions`, {
+				credentials: "include",
+			})
+			if (!res.ok) {
+				throw new Error("Something went wrong")
+			}
+
+			return res.json() as Promise<{ sessions: Session[] }>
+		},
+	})
+
+	const { mutate: revoke, isPending } = useMutation({
+		mutationFn: async (sessionId: string | null) => {
+			const res = await fetch(
+				sessionId
+					? `${SERVER_URL}/user/sessions/revoke`
+					: `${SERVER_URL}/user/sessions/revoke-others`,
+				{
+					method: "POST",
+					headers: { "Content-Type": "application/json" },
+					credentials: "include",
+					body: sessionId ? JSON.stringify({ sessionId }) : undefined,
+				}
+			)
+			if (!res.ok) {
+				throw new Error("Something went wrong")
+			}
+		},
+		onSettled: () => {
+			queryClient.invalidateQueries({ queryKey: ["sessions"] })
+		},
+	})
+
+	if (!data) return null
+
+	return (
+		<div className="mt-5 flex flex-col gap-3">
+			<p className="text-xl font-medium capitalize">active sessions</p>
+			{data.sessions.map((session) => (
+				<div
+					key={session.id}
+					className="flex items-center justify-between gap-4 text-sm"
+				>
+					<div className="flex flex-col">
+						<span className="break-all">
+							{session.userAgent ?? "Unknown device"}
+							{session.current ? (
+								<span className="font-medium text-primary">
+									{" "}
+									(this device)
+								</span>
+							) : null}
+						</span>
+						<span className="text-xs text-gray-500">
+							{session.ip ?? "Unknown IP"}, last seen{" "}
+							{new Date(session.lastSeenAt).toLocaleString()}, signed
+							in {new Date(session.createdAt).toLocaleString()}
+						</span>
+					</div>
+					{session.current ? null : (
+						<Button
+							size="sm"
+							variant="ghost"
+							disabled={isPending}
+							onClick={() => revoke(session.id)}
+						>
+							Revoke
+						</Button>
+					)}
+				</div>
+			))}
+			{data.sessions.length > 1 ? (
+				<Button
+					size="sm"
+					variant="secondary"
+					disabled={isPending}
+					onClick={() => revoke(null)}
+				>
+					Sign out everywhere else
+				</Button>
+			) : null}
+		</div>
+	)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Aaditya-23/server/internal/catalog"
+	"github.com/Aaditya-23/server/internal/database"
+)
+
+const usage = `usage:
+  server                                       start the http server
+  server import [-format csv|jsonl] [-dry-run] <file|->
+  server export [-format csv|jsonl] [-o file]
+`
+
+// runCommand runs a subcommand of the server binary and returns its exit
+// code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "import":
+		return importCommand(args[1:])
+	case "export":
+		return exportCommand(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+func importCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or jsonl, guessed from the file extension when not set")
+	dryRun := flags.Bool("dry-run", false, "only validate the products")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = catalog.FormatCSV
+		case ".jsonl", ".ndjson":
+			*format = catalog.FormatJSONLines
+		default:
+			fmt.Fprintln(os.Stderr, "cannot tell the format of", path, "set it with -format")
+			return 2
+		}
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	database.Init()
+	defer database.Close()
+
+	result, err := catalog.Import(input, *format, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	for _, rowErr := range result.Errors {
+		fmt.Fprintf(os.Stderr, "row %d: %s\n", rowErr.Row, rowErr.Error)
+	}
+
+	verb := "imported"
+	if result.DryRun {
+		verb = "valid"
+	}
+	fmt.Printf("%d %s, %d failed\n", result.Imported, verb, result.Failed)
+
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func exportCommand(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", catalog.FormatCSV, "csv or jsonl")
+	output := flags.String("o", "", "file to write to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *format != catalog.FormatCSV && *format != catalog.FormatJSONLines {
+		fmt.Fprintln(os.Stderr, "unknown format", *format)
+		return 2
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	database.Init()
+	defer database.Close()
+
+	if err := catalog.Export(out, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
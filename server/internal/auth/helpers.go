@@ -3,35 +3,82 @@ package auth
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
+	"net/url"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/Aaditya-23/server/internal/database"
 	"github.com/Aaditya-23/server/internal/utils"
 )
 
-func generateMagicToken(userId int64) (string, int64, error) {
-	tokenId, err := utils.GenerateUUID()
+const (
+	defaultMagicTokenTTL = 15 * time.Minute
+	// the token length in random bytes, not in characters
+	defaultMagicTokenSize = 32
+	minMagicTokenSize     = 16
+	tokenIdSize           = 16
+)
+
+// generateMagicToken returns the token to email along with the login the
+// requesting device polls with.
+func generateMagicToken(userId int64) (string, PendingLogin, error) {
+	var login PendingLogin
+
+	token, err := utils.RandomToken(magicTokenSize())
+	if err != nil {
+		return token, login, err
+	}
+
+	login.TokenId, err = utils.RandomToken(tokenIdSize)
+	if err != nil {
+		return token, login, err
+	}
+
+	login.Nonce, err = utils.RandomToken(magicTokenSize())
 	if err != nil {
-		return tokenId, 0, err
+		return token, login, err
 	}
 
-	now := time.Now().Unix()
-	data := fmt.Sprintf("%d%s%d", userId, tokenId, now)
+	err = database.CreateMagicToken(database.NewMagicToken{
+		PublicId:  login.TokenId,
+		TokenHash: hashToken(token),
+		NonceHash: hashToken(login.Nonce),
+		UserId:    userId,
+		TTL:       magicTokenTTL(),
+	})
+
+	return token, login, err
+}
+
+// hashToken is how tokens and nonces are stored, a leaked table does not give
+// away working links.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
 
-	hash := sha256.New()
-	if _, err = hash.Write([]byte(data)); err != nil {
-		return tokenId, 0, err
+// magicTokenTTL is how long a magic link stays valid, set with MAGIC_TOKEN_TTL
+// as a Go duration.
+func magicTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("MAGIC_TOKEN_TTL")); err == nil && ttl > 0 {
+		return ttl
 	}
-	token := hex.EncodeToString(hash.Sum(nil))
-	generatedTokenId, err := database.CreateMagicToken(token, userId)
+
+	return defaultMagicTokenTTL
+}
+
+// magicTokenSize is the number of random bytes in a token, set with
+// MAGIC_TOKEN_BYTES. Anything below minMagicTokenSize is raised to it.
+func magicTokenSize() int {
+	size, err := strconv.Atoi(os.Getenv("MAGIC_TOKEN_BYTES"))
 	if err != nil {
-		return token, 0, err
+		return defaultMagicTokenSize
 	}
 
-	return token, generatedTokenId, err
+	return max(size, minMagicTokenSize)
 }
 
 func generateLinkFromToken(token string) string {
-	return fmt.Sprintf("%s/auth/magic-link?token=%s", "https://react-go-rouge.vercel.app/", token)
+	return utils.FrontendURL("/auth/magic-link") + "?token=" + url.QueryEscape(token)
 }
@@ -1,37 +1,65 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"math"
+
 	"github.com/Aaditya-23/server/internal/database"
-	"github.com/Aaditya-23/server/internal/utils"
+	"github.com/Aaditya-23/server/internal/mail"
 )
 
-func SendMagicLink(userId int64, email string) (int64, error) {
-	var err error
-	magicToken, tokenId, err := generateMagicToken(userId)
-	if err != nil {
-		return tokenId, err
-	}
+var mailer mail.Mailer
 
-	mssg := "Click on this link to verify yourself\n" + generateLinkFromToken(magicToken)
-	err = utils.SendMail([]string{email}, mssg)
+// SetMailer sets where the auth emails are sent through, it has to be called
+// before any of them is sent.
+func SetMailer(m mail.Mailer) {
+	mailer = m
+}
 
-	return tokenId, err
+// PendingLogin is handed to the device that asked for a magic link. It polls
+// with both fields until the link is clicked, the nonce never leaves it.
+type PendingLogin struct {
+	TokenId string `json:"tokenId"`
+	Nonce   string `json:"nonce"`
 }
 
-func VerifyMagicToken(token string) (bool, error) {
-	var err error
-	isTokenVerified, err := database.VerifyMagicToken(token)
+// SendMagicLink emails the link in the given locale, see mail.MatchLocale.
+func SendMagicLink(ctx context.Context, userId int64, email, locale string) (PendingLogin, error) {
+	if mailer == nil {
+		return PendingLogin{}, errors.New("mailer is not set")
+	}
+
+	magicToken, login, err := generateMagicToken(userId)
 	if err != nil {
-		return isTokenVerified, err
+		return login, err
 	}
 
-	if isTokenVerified {
-		err = database.RegisterMagicToken(token)
+	msg, err := mail.Render(mail.TemplateMagicLink, locale, mail.MagicLinkData{
+		Link:             generateLinkFromToken(magicToken),
+		ExpiresInMinutes: int(math.Ceil(magicTokenTTL().Minutes())),
+	})
+	if err != nil {
+		return login, err
 	}
 
-	return isTokenVerified, err
+	msg.To = []string{email}
+	// a link that is no longer valid is not worth sending late
+	msg.ValidFor = magicTokenTTL()
+	err = mailer.Send(ctx, msg)
+
+	return login, err
+}
+
+// VerifyMagicToken registers the click on the emailed link, see
+// database.VerifyMagicToken for the errors.
+func VerifyMagicToken(token string) error {
+	return database.VerifyMagicToken(hashToken(token))
 }
 
-func CheckRegisteredMagicToken(tokenId int64) (bool, error) {
-	return database.CheckRegisteredMagicToken(tokenId)
+// ConsumeMagicToken exchanges a verified token for the id of its user, it
+// succeeds only once per token and only with the nonce of the pending login.
+// The bool is set when the token was the first to verify the user's email.
+func ConsumeMagicToken(login PendingLogin) (int64, bool, error) {
+	return database.ConsumeMagicToken(login.TokenId, hashToken(login.Nonce))
 }
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"os"
+	"time"
+
+	"github.com/Aaditya-23/server/internal/database"
+)
+
+const (
+	defaultSessionTTL            = 30 * 24 * time.Hour
+	defaultSessionRotateInterval = 24 * time.Hour
+	// seenInterval limits the writes a busy session causes, its last seen
+	// time and expiry are only moved this often.
+	seenInterval = 5 * time.Minute
+	// rotationGrace is how long the id a session was rotated away from still
+	// works, for the requests that were already on their way with it.
+	rotationGrace = time.Minute
+)
+
+// RenewedSession is set when the session cookie has to be sent again.
+type RenewedSession struct {
+	Id      string
+	Expires time.Time
+}
+
+func StartSession(userId int64, ip, userAgent string) (string, time.Time, error) {
+	return database.CreateUserSession(database.NewSession{
+		UserId:    userId,
+		IP:        ip,
+		UserAgent: userAgent,
+		TTL:       sessionTTL(),
+	})
+}
+
+// ResumeSession looks up the session of a request. An active session never
+// expires, its expiry slides sessionTTL ahead of its last request. The id is
+// rotated every SESSION_ROTATE_INTERVAL and after the role of the user
+// changes, the returned RenewedSession then holds the new cookie.
+func ResumeSession(sessionId, ip, userAgent string) (database.Session, *RenewedSession, error) {
+	session, err := database.FetchSession(sessionId, rotationGrace)
+	if err != nil {
+		return session, nil, err
+	}
+
+	var renewed *RenewedSession
+
+	// the cookie still holds the id the session was rotated away from
+	if session.Id != sessionId {
+		renewed = &RenewedSession{Id: session.Id, Expires: session.Expires}
+	}
+
+	if session.RotatedAt == nil || time.Since(*session.RotatedAt) > sessionRotateInterval() {
+		newId, err := database.RotateSession(session.Id)
+		if err != nil {
+			return session, nil, err
+		}
+
+		// an empty id means a concurrent request rotated it first, that
+		// request sends the new cookie
+		if newId != "" {
+			session.Id = newId
+			renewed = &RenewedSession{Id: newId, Expires: session.Expires}
+		}
+	}
+
+	if time.Since(session.LastSeenAt) > seenInterval || session.IP == nil || *session.IP != ip {
+		ttl := sessionTTL()
+		if err := database.TouchSession(session.Id, ip, userAgent, ttl); err != nil {
+			return session, nil, err
+		}
+
+		session.Expires = time.Now().Add(ttl)
+		renewed = &RenewedSession{Id: session.Id, Expires: session.Expires}
+	}
+
+	return session, renewed, nil
+}
+
+// sessionTTL is how long a session lasts without a request, set with
+// SESSION_TTL as a Go duration.
+func sessionTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+
+	return defaultSessionTTL
+}
+
+func sessionRotateInterval() time.Duration {
+	if interval, err := time.ParseDuration(os.Getenv("SESSION_ROTATE_INTERVAL")); err == nil && interval > 0 {
+		return interval
+	}
+
+	return defaultSessionRotateInterval
+}
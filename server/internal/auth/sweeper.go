@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/Aaditya-23/server/internal/database"
+)
+
+const (
+	sweepInterval = time.Hour
+	// magicTokenRetention keeps expired tokens around for a while so that
+	// late clicks are told the link expired rather than that it is invalid.
+	magicTokenRetention = 24 * time.Hour
+)
+
+// StartSweeper purges expired magic tokens and sessions every hour in the
+// background.
+func StartSweeper() {
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			sweep()
+			<-ticker.C
+		}
+	}()
+}
+
+func sweep() {
+	sweepMagicTokens()
+	sweepSessions()
+}
+
+func sweepMagicTokens() {
+	deleted, err := database.DeleteExpiredMagicTokens(magicTokenRetention)
+	if err != nil {
+		println("error occured while purging expired magic tokens", err.Error())
+		return
+	}
+
+	if deleted > 0 {
+		println("purged", deleted, "expired magic tokens")
+	}
+}
+
+func sweepSessions() {
+	deleted, err := database.DeleteExpiredSessions()
+	if err != nil {
+		println("error occured while purging expired sessions", err.Error())
+		return
+	}
+
+	if deleted > 0 {
+		println("purged", deleted, "expired sessions")
+	}
+}
@@ -0,0 +1,364 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Aaditya-23/server/internal/database"
+)
+
+// The CSV format has one row per product, or per variant for products that
+// have some. Variants of a product are on consecutive rows repeating its name,
+// their options in the option:<name> columns and their own price,
+// discountPercentage, stock and variantImageKeys. The description, status and
+// imageKeys of such a product are read from its first row. Image keys are
+// separated by |.
+const (
+	columnName               = "name"
+	columnDescription        = "description"
+	columnStatus             = "status"
+	columnPrice              = "price"
+	columnDiscountPercentage = "discountPercentage"
+	columnStock              = "stock"
+	columnImageKeys          = "imageKeys"
+	columnVariantImageKeys   = "variantImageKeys"
+
+	optionPrefix = "option:"
+)
+
+var csvColumns = []string{columnName, columnDescription, columnStatus, columnPrice, columnDiscountPercentage, columnStock, columnImageKeys, columnVariantImageKeys}
+
+const imageKeySeparator = "|"
+
+// csvRecord reads the cells of a record by column name.
+type csvRecord struct {
+	columns map[string]int
+	fields  []string
+}
+
+func (r csvRecord) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+
+	return strings.TrimSpace(r.fields[i])
+}
+
+func (r csvRecord) float(column string) (*float64, error) {
+	cell := r.get(column)
+	if cell == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return nil, errors.New(column + " must be a number")
+	}
+
+	return &value, nil
+}
+
+func (r csvRecord) int(column string) (*int64, error) {
+	cell := r.get(column)
+	if cell == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseInt(cell, 10, 64)
+	if err != nil {
+		return nil, errors.New(column + " must be an integer")
+	}
+
+	return &value, nil
+}
+
+func (r csvRecord) imageKeys(column string) []string {
+	cell := r.get(column)
+	if cell == "" {
+		return []string{}
+	}
+
+	return strings.Split(cell, imageKeySeparator)
+}
+
+func (r csvRecord) options(names []string) map[string]string {
+	options := map[string]string{}
+	for _, name := range names {
+		if value := r.get(optionPrefix + name); value != "" {
+			options[name] = value
+		}
+	}
+
+	return options
+}
+
+func readCSV(r io.Reader) ([]row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	// rows with the wrong number of cells fail on their own instead of
+	// ending the import
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("missing csv header")
+	}
+
+	known := map[string]bool{}
+	for _, column := range csvColumns {
+		known[column] = true
+	}
+
+	columns := map[string]int{}
+	var optionNames []string
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+
+		if name, ok := strings.CutPrefix(column, optionPrefix); ok && name != "" {
+			optionNames = append(optionNames, name)
+		} else if !known[column] {
+			return nil, errors.New("unknown csv column " + column)
+		}
+
+		columns[column] = i
+	}
+
+	if _, ok := columns[columnName]; !ok {
+		return nil, errors.New("csv is missing the name column")
+	}
+
+	var rows []row
+	// current is the index of the product with variants that the following
+	// rows may still add to, -1 when there is none
+	current := -1
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+
+			current = -1
+			rows = append(rows, row{line: parseErr.StartLine, err: errors.New("invalid csv row: " + parseErr.Err.Error())})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(fields) != len(header) {
+			current = -1
+			rows = append(rows, row{line: line, err: fmt.Errorf("row has %d cells, the header has %d", len(fields), len(header))})
+			continue
+		}
+
+		record := csvRecord{columns: columns, fields: fields}
+		options := record.options(optionNames)
+
+		if len(options) == 0 {
+			current = -1
+			rows = append(rows, readCSVProduct(line, record))
+			continue
+		}
+
+		if current == -1 || rows[current].product.Name != record.get(columnName) {
+			product := readCSVProduct(line, record)
+
+			variants := []map[string]any{}
+			product.product.Variants = &variants
+			product.product.Price = nil
+			product.product.DiscountPercentage = nil
+			product.product.Stock = nil
+
+			rows = append(rows, product)
+			current = len(rows) - 1
+		}
+
+		if rows[current].err != nil {
+			continue
+		}
+
+		variant, err := readCSVVariant(record, options)
+		if err != nil {
+			rows[current].err = err
+			continue
+		}
+
+		*rows[current].product.Variants = append(*rows[current].product.Variants, variant)
+	}
+
+	return rows, nil
+}
+
+func readCSVProduct(line int, record csvRecord) row {
+	row := row{line: line}
+
+	status := record.get(columnStatus)
+	imageKeys := record.imageKeys(columnImageKeys)
+
+	row.product = Product{
+		Name:        record.get(columnName),
+		Description: record.get(columnDescription),
+		ImageKeys:   &imageKeys,
+	}
+	if status != "" {
+		row.product.Status = &status
+	}
+
+	row.product.Price, row.err = record.float(columnPrice)
+	if row.err != nil {
+		return row
+	}
+
+	row.product.DiscountPercentage, row.err = record.float(columnDiscountPercentage)
+	if row.err != nil {
+		return row
+	}
+
+	row.product.Stock, row.err = record.int(columnStock)
+
+	return row
+}
+
+// readCSVVariant builds a variant the way it is sent to POST /product, so that
+// it goes through the same validation.
+func readCSVVariant(record csvRecord, options map[string]string) (map[string]any, error) {
+	variant := map[string]any{}
+	for name, value := range options {
+		variant[name] = value
+	}
+
+	price, err := record.float(columnPrice)
+	if err != nil {
+		return variant, err
+	}
+	if price != nil {
+		variant["price"] = *price
+	}
+
+	discountPercentage, err := record.float(columnDiscountPercentage)
+	if err != nil {
+		return variant, err
+	}
+	if discountPercentage != nil {
+		variant["discountPercentage"] = *discountPercentage
+	}
+
+	stock, err := record.int(columnStock)
+	if err != nil {
+		return variant, err
+	}
+	if stock != nil {
+		variant["stock"] = float64(*stock)
+	}
+
+	imageKeys := []any{}
+	for _, key := range record.imageKeys(columnVariantImageKeys) {
+		imageKeys = append(imageKeys, key)
+	}
+	variant["imageKeys"] = imageKeys
+
+	return variant, nil
+}
+
+func writeCSV(w io.Writer, products []database.Product) error {
+	optionSet := map[string]bool{}
+	for _, product := range products {
+		for _, variant := range product.Variants {
+			for key := range variant {
+				if !isVariantAttribute(key) {
+					optionSet[key] = true
+				}
+			}
+		}
+	}
+
+	optionNames := make([]string, 0, len(optionSet))
+	for name := range optionSet {
+		optionNames = append(optionNames, name)
+	}
+	sort.Strings(optionNames)
+
+	header := append([]string{}, csvColumns...)
+	for _, name := range optionNames {
+		header = append(header, optionPrefix+name)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		base := []string{
+			product.Name,
+			stringValue(product.Description),
+			product.Status,
+		}
+		imageKeys := strings.Join(product.ImageKeys, imageKeySeparator)
+
+		if len(product.Variants) == 0 {
+			record := append(base, floatCell(product.Price), floatCell(product.DiscountPercentage), strconv.FormatInt(product.Stock, 10), imageKeys, "")
+			record = append(record, make([]string, len(optionNames))...)
+
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, variant := range product.Variants {
+			price, _ := variant["price"].(float64)
+			discountPercentage, hasDiscount := variant["discountPercentage"].(float64)
+			stock, _ := variant["stock"].(int64)
+			variantImageKeys, _ := variant["imageKeys"].([]string)
+
+			discountCell := ""
+			if hasDiscount {
+				discountCell = floatCell(&discountPercentage)
+			}
+
+			record := append(append([]string{}, base...), floatCell(&price), discountCell, strconv.FormatInt(stock, 10), imageKeys, strings.Join(variantImageKeys, imageKeySeparator))
+			for _, name := range optionNames {
+				value, _ := variant[name].(string)
+				record = append(record, value)
+			}
+
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func isVariantAttribute(key string) bool {
+	return key == "price" || key == "discountPercentage" || key == "stock" || key == "imageKeys"
+}
+
+func floatCell(value *float64) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/Aaditya-23/server/internal/database"
+)
+
+const exportBatchSize = 200
+
+// Export writes every draft and active product in the given format, in the
+// same shape Import reads them. Archived products are left out.
+func Export(w io.Writer, format string) error {
+	if format != FormatCSV && format != FormatJSONLines {
+		return errors.New("unknown format " + format)
+	}
+
+	products := []database.Product{}
+
+	var afterId int64
+	for {
+		batch, err := database.FetchAllProducts(afterId, exportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, product := range batch {
+			if product.Status != database.ProductArchived {
+				products = append(products, product)
+			}
+		}
+
+		if len(batch) < exportBatchSize {
+			break
+		}
+		afterId = batch[len(batch)-1].Id
+	}
+
+	if format == FormatCSV {
+		return writeCSV(w, products)
+	}
+
+	return writeJSONLines(w, products)
+}
+
+func writeJSONLines(w io.Writer, products []database.Product) error {
+	encoder := json.NewEncoder(w)
+
+	for _, product := range products {
+		status := product.Status
+		imageKeys := product.ImageKeys
+
+		row := Product{
+			Name:        product.Name,
+			Description: stringValue(product.Description),
+			Status:      &status,
+			ImageKeys:   &imageKeys,
+		}
+
+		if len(product.Variants) > 0 {
+			variants := product.Variants
+			row.Variants = &variants
+		} else {
+			stock := product.Stock
+			row.Price = product.Price
+			row.DiscountPercentage = product.DiscountPercentage
+			row.Stock = &stock
+		}
+
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
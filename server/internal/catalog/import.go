@@ -0,0 +1,124 @@
+package catalog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/Aaditya-23/server/internal/database"
+)
+
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+)
+
+var Formats = []string{FormatCSV, FormatJSONLines}
+
+// maxLineSize bounds a single JSON lines product, variants included.
+const maxLineSize = 1 << 20
+
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult tells how many products were imported, or would have been on
+// a dry run, and why the other rows were not.
+type ImportResult struct {
+	DryRun   bool       `json:"dryRun"`
+	Imported int        `json:"imported"`
+	Failed   int        `json:"failed"`
+	Errors   []RowError `json:"errors"`
+}
+
+// row is one product read from the input along with the line it starts on,
+// err is set when the row could not even be parsed.
+type row struct {
+	line    int
+	product Product
+	err     error
+}
+
+// Import reads products in the given format and creates every valid one,
+// unless dryRun is set. A bad row is reported in the result and does not stop
+// the others, an error is only returned when the input cannot be read at all.
+func Import(r io.Reader, format string, dryRun bool) (ImportResult, error) {
+	result := ImportResult{DryRun: dryRun, Errors: []RowError{}}
+
+	var (
+		rows []row
+		err  error
+	)
+
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(r)
+	case FormatJSONLines:
+		rows, err = readJSONLines(r)
+	default:
+		err = errors.New("unknown format " + format)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	for _, row := range rows {
+		err := row.err
+		if err == nil {
+			err = row.product.Validate()
+		}
+
+		if err == nil {
+			if dryRun {
+				err = row.product.CheckImages()
+			} else {
+				err = row.product.Create()
+			}
+
+			if err != nil && err != database.ErrImageNotFound {
+				println("error occured while importing a product", err.Error())
+				err = errors.New("could not save the product")
+			}
+		}
+
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, RowError{Row: row.line, Error: err.Error()})
+			continue
+		}
+
+		result.Imported++
+	}
+
+	return result, nil
+}
+
+// readJSONLines reads one product per line, blank lines are skipped.
+func readJSONLines(r io.Reader) ([]row, error) {
+	var rows []row
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := row{line: line}
+		if err := json.Unmarshal([]byte(text), &row.product); err != nil {
+			row.err = errors.New("invalid json")
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
@@ -0,0 +1,195 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/utils"
+	v "github.com/aaditya-23/validator"
+)
+
+const MaxProductImages = 10
+
+// Product is a new product as accepted by POST /product and by the bulk
+// import, so that both go through the same validation.
+type Product struct {
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	Price              *float64          `json:"price"`
+	DiscountPercentage *float64          `json:"discountPercentage"`
+	Stock              *int64            `json:"stock"`
+	Status             *string           `json:"status"`
+	ImageKeys          *[]string         `json:"imageKeys"`
+	Variants           *[]map[string]any `json:"variants"`
+}
+
+// Validate returns the first problem found with the product, if any.
+func (p *Product) Validate() error {
+	errs := v.Struct(p).
+		Fields(
+			v.String(&p.Name, "name").Min(1),
+			v.String(&p.Description, "description").Min(1),
+			v.Number(p.Price, "price").Optional(),
+			v.Number(p.DiscountPercentage, "discountPercentage").Optional(),
+			v.Number(p.Stock, "stock").Optional().Refine(utils.AtLeast[int64]("stock", 0)),
+			v.String(p.Status, "status").Optional().Refine(utils.OneOf("status", []string{database.ProductDraft, database.ProductActive})),
+			v.Slice(p.ImageKeys, "imageKeys").Optional().Refine(utils.MaxItems[string]("imageKeys", MaxProductImages)),
+			v.Slice(p.Variants, "variants").Optional().Refine(ValidateVariants),
+		).
+		Refine(func(p Product) error {
+			if p.Price == nil && p.Variants == nil {
+				return errors.New("price or variants is required")
+			}
+
+			return nil
+		}).
+		Parse()
+
+	if len(errs) > 0 {
+		return errors.New(errs[0].Message)
+	}
+
+	return nil
+}
+
+// Create saves a product that passed Validate.
+func (p *Product) Create() error {
+	var imageKeys []string
+	if p.ImageKeys != nil {
+		imageKeys = *p.ImageKeys
+	}
+
+	var status string
+	if p.Status != nil {
+		status = *p.Status
+	}
+
+	if p.Price != nil {
+		var stock int64
+		if p.Stock != nil {
+			stock = *p.Stock
+		}
+
+		return database.CreateProduct(database.NewProduct{
+			Name:               p.Name,
+			Description:        p.Description,
+			Price:              *p.Price,
+			DiscountPercentage: p.DiscountPercentage,
+			Stock:              stock,
+			Status:             status,
+			ImageKeys:          imageKeys,
+		})
+	}
+
+	return database.CreateProductWithVariants(database.NewProductWithVariants{
+		Name:        p.Name,
+		Description: p.Description,
+		Status:      status,
+		ImageKeys:   imageKeys,
+		Variants:    *p.Variants,
+	})
+}
+
+// CheckImages returns database.ErrImageNotFound unless every image the product
+// and its variants refer to was uploaded, Create makes the same check.
+func (p *Product) CheckImages() error {
+	var imageKeys []string
+	if p.ImageKeys != nil {
+		imageKeys = append(imageKeys, *p.ImageKeys...)
+	}
+
+	if p.Variants != nil {
+		for _, variant := range *p.Variants {
+			keys, _ := variant["imageKeys"].([]any)
+			for _, key := range keys {
+				if key, ok := key.(string); ok {
+					imageKeys = append(imageKeys, key)
+				}
+			}
+		}
+	}
+
+	return database.CheckImagesExist(imageKeys)
+}
+
+// ValidateVariants checks every variant has a numeric price, optionally a
+// numeric discountPercentage and stock, a list of imageKeys, and only string
+// option values. Option names and values are compared without regard to case,
+// as the database does, so no two options of a variant and no two variants may
+// differ only in case.
+func ValidateVariants(variants []map[string]any) error {
+	seen := map[string]bool{}
+
+	for _, v := range variants {
+		price, ok := v["price"]
+		if !ok {
+			return errors.New("every variant must have a price")
+		}
+		_, ok = price.(float64)
+		if !ok {
+			return errors.New("price must be of type integer")
+		}
+
+		// null leaves the variant without a discount
+		discountPercentage, ok := v["discountPercentage"]
+		if ok && discountPercentage != nil {
+			if _, ok := discountPercentage.(float64); !ok {
+				return errors.New("discount percentage must be of type integer")
+			}
+		}
+
+		stock, ok := v["stock"]
+		if ok {
+			if value, ok := stock.(float64); !ok || value < 0 || value != float64(int64(value)) {
+				return errors.New("stock must be a non-negative integer")
+			}
+		}
+
+		imageKeys, ok := v["imageKeys"]
+		if ok {
+			keys, ok := imageKeys.([]any)
+			if !ok || len(keys) > MaxProductImages {
+				return fmt.Errorf("imageKeys must be a list of at most %d image keys", MaxProductImages)
+			}
+
+			for _, key := range keys {
+				if _, ok := key.(string); !ok {
+					return errors.New("every image key must be of type string")
+				}
+			}
+		}
+
+		var options []string
+		names := map[string]bool{}
+		for key, value := range v {
+			if key == "price" || key == "discountPercentage" || key == "stock" || key == "imageKeys" {
+				continue
+			}
+
+			text, ok := value.(string)
+			if !ok {
+				return errors.New("value of variant-type must be of type string")
+			}
+
+			name := strings.ToLower(key)
+			if names[name] {
+				return errors.New("variant-types cannot differ only in case")
+			}
+			names[name] = true
+
+			options = append(options, name+"\x00"+strings.ToLower(text))
+		}
+
+		sort.Strings(options)
+		key := strings.Join(options, "\x01")
+		if seen[key] {
+			return errors.New("every variant must have different variant-type values")
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
@@ -2,19 +2,19 @@ package database
 
 import (
 	"database/sql"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"strings"
 )
 
 type ProductDetail struct {
 	Id                 int64              `json:"id"`
+	VariantId          *int64             `json:"variantId"`
 	Name               string             `json:"name"`
 	Price              *float64           `json:"price"`
 	DiscountPercentage *float64           `json:"discountPercentage"`
 	Quantity           int                `json:"quantity"`
 	Variant            *map[string]string `json:"variant"`
+	// Status lets the cart show products that were archived since they were
+	// added, they cannot be ordered anymore.
+	Status string `json:"status"`
 }
 
 type CartDetails struct {
@@ -46,130 +46,89 @@ func CreateCart(userId int64) (int64, error) {
 }
 
 func GetCartDetails(cartId int64) (CartDetails, error) {
-	const query = `SELECT T1.quantity, T1.variant, T2.name, T2.price, T2.discount_percentage, T2.id as product_id, T2.variants as product_variants
+	return getCartDetails(db, cartId)
+}
+
+func getCartDetails(q querier, cartId int64) (CartDetails, error) {
+	const query = `SELECT T1.quantity, T1.variant_id, T2.name, COALESCE(T3.price, T2.price), IF(T3.id IS NULL, T2.discount_percentage, T3.discount_percentage), T2.id as product_id, T2.status
 	FROM cart_items as T1
 	JOIN products as T2
 	ON T1.product_id = T2.id
-	WHERE cart_id = ?`
+	LEFT JOIN product_variants as T3
+	ON T1.variant_id = T3.id
+	WHERE cart_id = ?
+	ORDER BY T1.id`
 
 	var cartDetails CartDetails
 	cartDetails.Id = cartId
 
-	rows, err := db.Query(query, cartId)
+	rows, err := q.Query(query, cartId)
 	if err != nil {
 		return cartDetails, err
 	}
+	defer rows.Close()
 
+	var variantIds []int64
 	for rows.Next() {
 		var product ProductDetail
-		var selectedVariantBytes *[]byte
-		var productVariantsBytes []byte
 
-		err := rows.Scan(&product.Quantity, &selectedVariantBytes, &product.Name, &product.Price, &product.DiscountPercentage, &product.Id, &productVariantsBytes)
+		err := rows.Scan(&product.Quantity, &product.VariantId, &product.Name, &product.Price, &product.DiscountPercentage, &product.Id, &product.Status)
 		if err != nil {
 			return cartDetails, err
 		}
 
-		if selectedVariantBytes != nil {
-			if err := json.Unmarshal(*selectedVariantBytes, &product.Variant); err != nil {
-				return cartDetails, err
-			}
-		} else {
-			cartDetails.Products = append(cartDetails.Products, product)
-			continue
+		if product.VariantId != nil {
+			variantIds = append(variantIds, *product.VariantId)
 		}
 
-		var productVariants []map[string]any
-		if err := json.Unmarshal(productVariantsBytes, &productVariants); err != nil {
-			return cartDetails, err
-		}
+		cartDetails.Products = append(cartDetails.Products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return cartDetails, err
+	}
 
-	Outer:
-		for _, variant := range productVariants {
-			for key, value := range *product.Variant {
-				variantValue, ok := variant[key]
-				if !ok {
-					return cartDetails, errors.New("invalid variant found")
-				}
-
-				if variantValue != value {
-					continue Outer
-				}
-			}
-
-			price, ok := variant["price"]
-			if !ok {
-				return cartDetails, errors.New("price missing from variant")
-			}
-			convertedPrice, ok := price.(float64)
-			if !ok {
-				return cartDetails, errors.New("incorrect price value")
-			}
-			product.Price = &convertedPrice
-
-			discountPercentage, ok := variant["discountPercentage"]
-			if ok {
-				convertedDiscountPercentage, ok := discountPercentage.(float64)
-				if !ok {
-					return cartDetails, errors.New("incorrect discount percentage value")
-				}
-				product.DiscountPercentage = &convertedDiscountPercentage
-			}
-			break
+	options, err := fetchVariantOptions(q, variantIds)
+	if err != nil {
+		return cartDetails, err
+	}
+
+	for i, product := range cartDetails.Products {
+		if product.VariantId == nil {
+			continue
 		}
 
-		cartDetails.Products = append(cartDetails.Products, product)
+		variant := options[*product.VariantId]
+		cartDetails.Products[i].Variant = &variant
 	}
 
-	return cartDetails, err
+	return cartDetails, nil
 }
 
 func CheckProductVariants(cartId, productId int64) (int64, bool, error) {
-	const query = `SELECT T1.id, variants FROM cart_items AS T1 JOIN products AS T2 ON product_id = T2.id WHERE cart_id = ? AND product_id = ?`
+	const query = `SELECT id, variant_id IS NOT NULL FROM cart_items WHERE cart_id = ? AND product_id = ?`
 
 	var (
-		variantBytes []byte
-		variants     []map[string]any
-		cartItemId   int64
+		cartItemId  int64
+		hasVariants bool
 	)
 
-	if err := db.QueryRow(query, cartId, productId).Scan(&cartItemId, &variantBytes); err != nil {
-		return cartItemId, false, err
-	}
-
-	if err := json.Unmarshal(variantBytes, &variants); err != nil {
-		return cartItemId, false, err
-	}
-
-	if len(variants) > 0 {
-		return cartItemId, true, nil
-	}
+	err := db.QueryRow(query, cartId, productId).Scan(&cartItemId, &hasVariants)
 
-	return cartItemId, false, nil
+	return cartItemId, hasVariants, err
 }
 
 func AddVariantProductToCart(cartId, productId int64, variant map[string]string) error {
-	query := `SELECT T1.id FROM cart_items AS T1 JOIN products AS T2 ON product_id = T2.id WHERE cart_id = ? AND product_id = ? AND JSON_CONTAINS(variant, JSON_OBJECT(`
-
-	for key, value := range variant {
-		query += fmt.Sprintf("'%s', '%s',", key, value)
+	variantId, err := findVariantId(db, productId, variant)
+	if err != nil {
+		return err
 	}
-	query, _ = strings.CutSuffix(query, ",")
-	query += "))"
+
+	const query = `SELECT id FROM cart_items WHERE cart_id = ? AND variant_id = ?`
 
 	var cartItemId int64
-	err := db.QueryRow(query, cartId, productId).Scan(&cartItemId)
+	err = db.QueryRow(query, cartId, variantId).Scan(&cartItemId)
 	if err == sql.ErrNoRows {
-		const insertQuery = `INSERT INTO cart_items (cart_id, product_id, variant, quantity) VALUES (?, ?, ?, ?)`
-
-		variantBytes, err := json.Marshal(variant)
-		if err != nil {
-			return err
-		}
-
-		_, err = db.Exec(insertQuery, cartId, productId, string(variantBytes), 1)
-
-		return err
+		return addProductToCart(cartId, productId, &variantId)
 	} else if err != nil {
 		return err
 	} else {
@@ -179,56 +138,76 @@ func AddVariantProductToCart(cartId, productId int64, variant map[string]string)
 }
 
 func IncrementProductQuantityInCart(cartItemId int64) error {
+	const selectQuery = `SELECT product_id, variant_id, quantity FROM cart_items WHERE id = ?`
+
+	var (
+		productId int64
+		variantId *int64
+		quantity  int64
+	)
+
+	if err := db.QueryRow(selectQuery, cartItemId).Scan(&productId, &variantId, &quantity); err != nil {
+		return err
+	}
+
+	stock, err := availableStock(db, productId, variantId)
+	if err != nil {
+		return err
+	}
+
+	if quantity+1 > stock {
+		return ErrOutOfStock
+	}
+
 	const query = `UPDATE cart_items SET quantity = quantity + 1 WHERE id = ?`
 
-	_, err := db.Exec(query, cartItemId)
+	_, err = db.Exec(query, cartItemId)
 	return err
 }
 
 func AddNewProductToCart(cartId, productId int64, variant map[string]string) error {
-	query := `INSERT INTO cart_items (cart_id, product_id, quantity`
-
-	if len(variant) > 0 {
-		query += ", variant"
+	if len(variant) == 0 {
+		return addProductToCart(cartId, productId, nil)
 	}
 
-	query += ") VALUES (?, ?, ?"
-
-	if len(variant) > 0 {
-		query += ", ?"
+	variantId, err := findVariantId(db, productId, variant)
+	if err != nil {
+		return err
 	}
 
-	query += ")"
+	return addProductToCart(cartId, productId, &variantId)
+}
 
-	variantBytes, err := json.Marshal(variant)
+func addProductToCart(cartId, productId int64, variantId *int64) error {
+	stock, err := availableStock(db, productId, variantId)
 	if err != nil {
 		return err
 	}
 
-	if len(variant) > 0 {
-		_, err = db.Exec(query, cartId, productId, 1, string(variantBytes))
-	} else {
-		_, err = db.Exec(query, cartId, productId, 1)
+	if stock < 1 {
+		return ErrOutOfStock
 	}
 
+	const query = `INSERT INTO cart_items (cart_id, product_id, variant_id, quantity) VALUES (?, ?, ?, ?)`
+
+	_, err = db.Exec(query, cartId, productId, variantId, 1)
 	return err
 }
 
 func DeleteVariantProductFromCart(cartId, productId int64, variant map[string]string) error {
-	query := `SELECT id, quantity FROM cart_items WHERE cart_id = ? AND product_id = ? AND JSON_CONTAINS(variant, JSON_OBJECT(`
-
-	for key, value := range variant {
-		query += fmt.Sprintf("'%s', '%s',", key, value)
+	variantId, err := findVariantId(db, productId, variant)
+	if err != nil {
+		return err
 	}
-	query, _ = strings.CutSuffix(query, ",")
-	query += "))"
+
+	const query = `SELECT id, quantity FROM cart_items WHERE cart_id = ? AND variant_id = ?`
 
 	var (
 		cartItemId int64
 		quantity   int64
 	)
 
-	err := db.QueryRow(query, cartId, productId).Scan(&cartItemId, &quantity)
+	err = db.QueryRow(query, cartId, variantId).Scan(&cartItemId, &quantity)
 	if err != nil {
 		return err
 	}
@@ -281,10 +260,3 @@ func DecrementProductFromCart(cartItemId int64) error {
 
 	return err
 }
-
-func Order(cartId int64) error {
-	const query = `DELETE FROM carts WHERE id = ?`
-	_, err := db.Exec(query, cartId)
-
-	return err
-}
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrCategoryHasChildren = errors.New("category has child categories")
+	ErrCategoryCycle       = errors.New("category cannot be nested under itself or one of its descendants")
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategorySlugTaken   = errors.New("category slug is already taken")
+)
+
+type Category struct {
+	Id       int64      `json:"id"`
+	Name     string     `json:"name"`
+	Slug     string     `json:"slug"`
+	ParentId *int64     `json:"parentId"`
+	Children []Category `json:"children"`
+}
+
+type NewCategory struct {
+	Name     string
+	Slug     string
+	ParentId *int64
+}
+
+// CategoryPatch holds the fields of a category to update. Setting
+// RemoveParent moves the category to the top level.
+type CategoryPatch struct {
+	Name         *string
+	Slug         *string
+	ParentId     *int64
+	RemoveParent bool
+}
+
+// descendantCategoriesQuery selects the id of the category with the given slug
+// along with the ids of all of its descendants.
+const descendantCategoriesQuery = `WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE slug = ?
+		UNION ALL
+		SELECT T1.id FROM categories AS T1 JOIN tree AS T2 ON T1.parent_id = T2.id
+	) SELECT id FROM tree`
+
+func CreateCategory(category NewCategory) (int64, error) {
+	if category.ParentId != nil {
+		if err := checkCategoryExists(*category.ParentId); err != nil {
+			return 0, err
+		}
+	}
+
+	const query = `INSERT INTO categories (name, slug, parent_id) VALUES (?, ?, ?)`
+
+	result, err := db.Exec(query, category.Name, category.Slug, category.ParentId)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return 0, ErrCategorySlugTaken
+		}
+
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// FetchCategories returns every top level category with its children nested
+// under it.
+func FetchCategories() ([]Category, error) {
+	const query = `SELECT id, name, slug, parent_id FROM categories ORDER BY name`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.Id, &category.Name, &category.Slug, &category.ParentId); err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	children := map[int64][]Category{}
+	for _, category := range categories {
+		if category.ParentId != nil {
+			children[*category.ParentId] = append(children[*category.ParentId], category)
+		}
+	}
+
+	var build func(category Category) Category
+	build = func(category Category) Category {
+		category.Children = []Category{}
+		for _, child := range children[category.Id] {
+			category.Children = append(category.Children, build(child))
+		}
+
+		return category
+	}
+
+	tree := []Category{}
+	for _, category := range categories {
+		if category.ParentId == nil {
+			tree = append(tree, build(category))
+		}
+	}
+
+	return tree, nil
+}
+
+func UpdateCategory(categoryId int64, patch CategoryPatch) error {
+	if err := checkCategoryExists(categoryId); err != nil {
+		return err
+	}
+
+	if patch.ParentId != nil {
+		if err := checkCategoryExists(*patch.ParentId); err != nil {
+			return err
+		}
+
+		isDescendant, err := isDescendantCategory(*patch.ParentId, categoryId)
+		if err != nil {
+			return err
+		}
+
+		if isDescendant {
+			return ErrCategoryCycle
+		}
+	}
+
+	var query queryBuilder
+	query.write(`UPDATE categories SET updated_at = CURRENT_TIMESTAMP`)
+
+	if patch.Name != nil {
+		query.write(", name = ?", *patch.Name)
+	}
+	if patch.Slug != nil {
+		query.write(", slug = ?", *patch.Slug)
+	}
+	if patch.RemoveParent {
+		query.write(", parent_id = NULL")
+	} else if patch.ParentId != nil {
+		query.write(", parent_id = ?", *patch.ParentId)
+	}
+	query.write(" WHERE id = ?", categoryId)
+
+	updateQuery, args := query.build()
+	_, err := db.Exec(updateQuery, args...)
+	if isDuplicateEntry(err) {
+		return ErrCategorySlugTaken
+	}
+
+	return err
+}
+
+// DeleteCategory removes a category that has no children, unlinking it from
+// every product.
+func DeleteCategory(categoryId int64) error {
+	if err := checkCategoryExists(categoryId); err != nil {
+		return err
+	}
+
+	const childrenQuery = `SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = ?)`
+
+	var hasChildren bool
+	if err := db.QueryRow(childrenQuery, categoryId).Scan(&hasChildren); err != nil {
+		return err
+	}
+
+	if hasChildren {
+		return ErrCategoryHasChildren
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const unlinkQuery = `DELETE FROM product_categories WHERE category_id = ?`
+	if _, err := tx.Exec(unlinkQuery, categoryId); err != nil {
+		return err
+	}
+
+	const deleteQuery = `DELETE FROM categories WHERE id = ?`
+	if _, err := tx.Exec(deleteQuery, categoryId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetProductCategories replaces the categories a product belongs to.
+func SetProductCategories(productId int64, categoryIds []int64) error {
+	for _, categoryId := range categoryIds {
+		if err := checkCategoryExists(categoryId); err != nil {
+			return err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const deleteQuery = `DELETE FROM product_categories WHERE product_id = ?`
+	if _, err := tx.Exec(deleteQuery, productId); err != nil {
+		return err
+	}
+
+	const insertQuery = `INSERT IGNORE INTO product_categories (product_id, category_id) VALUES (?, ?)`
+	for _, categoryId := range categoryIds {
+		if _, err := tx.Exec(insertQuery, productId, categoryId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func checkCategoryExists(categoryId int64) error {
+	const query = `SELECT id FROM categories WHERE id = ?`
+
+	var id int64
+	err := db.QueryRow(query, categoryId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrCategoryNotFound
+	}
+
+	return err
+}
+
+// isDescendantCategory reports whether categoryId is ancestorId itself or one
+// of its descendants.
+func isDescendantCategory(categoryId, ancestorId int64) (bool, error) {
+	const query = `WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE id = ?
+		UNION ALL
+		SELECT T1.id FROM categories AS T1 JOIN tree AS T2 ON T1.parent_id = T2.id
+	) SELECT EXISTS(SELECT 1 FROM tree WHERE id = ?)`
+
+	var isDescendant bool
+	err := db.QueryRow(query, ancestorId, categoryId).Scan(&isDescendant)
+	return isDescendant, err
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks the last item of a page. It is handed to clients as an opaque
+// string and only valid for the ordering it was created for.
+type cursor struct {
+	Order string `json:"o"`
+	Key   any    `json:"k"`
+	Id    int64  `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor created for the given ordering, its key has to
+// be a string when the ordering is on text and a number otherwise.
+func decodeCursor(value, order string, text bool) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil || c.Order != order {
+		return c, ErrInvalidCursor
+	}
+
+	switch c.Key.(type) {
+	case float64:
+		if !text {
+			return c, nil
+		}
+	case string:
+		if text {
+			return c, nil
+		}
+	}
+
+	return c, ErrInvalidCursor
+}
+
+type ProductPage struct {
+	Products   []Product `json:"products"`
+	NextCursor *string   `json:"nextCursor"`
+	// Count is the total number of matching products, only set when asked for.
+	Count *int64 `json:"count,omitempty"`
+}
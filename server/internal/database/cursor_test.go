@@ -0,0 +1,52 @@
+package database
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	encoded := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		order   string
+		text    bool
+		want    cursor
+		wantErr bool
+	}{
+		{"number key", encodeCursor(cursor{Order: "price", Key: 9.5, Id: 3}), "price", false, cursor{Order: "price", Key: 9.5, Id: 3}, false},
+		{"text key", encodeCursor(cursor{Order: "name", Key: "shirt", Id: 7}), "name", true, cursor{Order: "name", Key: "shirt", Id: 7}, false},
+		{"default order", encodeCursor(cursor{Key: 1700000000.0, Id: 1}), "", false, cursor{Key: 1700000000.0, Id: 1}, false},
+		{"other order", encodeCursor(cursor{Order: "price", Key: 9.5, Id: 3}), "-price", false, cursor{}, true},
+		{"text key for a number order", encodeCursor(cursor{Order: "price", Key: "9.5", Id: 3}), "price", false, cursor{}, true},
+		{"number key for a text order", encodeCursor(cursor{Order: "name", Key: 1.0, Id: 7}), "name", true, cursor{}, true},
+		{"missing key", encoded(`{"o":"price","i":3}`), "price", false, cursor{}, true},
+		{"object key", encoded(`{"o":"price","k":{},"i":3}`), "price", false, cursor{}, true},
+		{"not base64", "not a cursor!", "price", false, cursor{}, true},
+		{"not json", encoded("price"), "price", false, cursor{}, true},
+		{"empty", "", "price", false, cursor{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.value, tt.order, tt.text)
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Errorf("decodeCursor() error = %v, want %v", err, ErrInvalidCursor)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("decodeCursor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
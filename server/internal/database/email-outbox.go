@@ -0,0 +1,272 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	EmailPending = "pending"
+	// EmailSending is set while a worker holds the email, it is claimed again
+	// once next_attempt_at passes in case that worker died mid send.
+	EmailSending = "sending"
+	EmailSent    = "sent"
+	// EmailDead is the dead letter state, the email ran out of attempts or
+	// passed its not_after. It is only sent again when it is requeued, which
+	// emails with a not_after cannot be as their bodies are cleared.
+	EmailDead = "dead"
+)
+
+var EmailStatuses = []string{EmailPending, EmailSending, EmailSent, EmailDead}
+
+var (
+	ErrEmailNotDead = errors.New("only dead emails can be requeued")
+	ErrEmailExpired = errors.New("the email expired and cannot be requeued")
+)
+
+type NewEmail struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	// ValidFor is how long the email is worth sending, zero when it does not
+	// expire. Emails that expire are treated as carrying a credential.
+	ValidFor time.Duration
+}
+
+// OutboxEmail is a queued email. The bodies are cleared once it is sent, and
+// once it is dead when it has a NotAfter, so that the links in them are not
+// kept around.
+type OutboxEmail struct {
+	Id            int64      `json:"id"`
+	From          string     `json:"-"`
+	To            []string   `json:"to"`
+	Subject       string     `json:"subject"`
+	Text          string     `json:"-"`
+	HTML          string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"lastError"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	NotAfter      *time.Time `json:"notAfter"`
+	SentAt        *time.Time `json:"sentAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+func EnqueueEmail(email NewEmail) (int64, error) {
+	const query = `INSERT INTO email_outbox (sender, recipients, subject, text_body, html_body, not_after) VALUES (?, ?, ?, ?, ?, IF(? > 0, CURRENT_TIMESTAMP + INTERVAL ? SECOND, NULL))`
+
+	recipients, err := json.Marshal(email.To)
+	if err != nil {
+		return 0, err
+	}
+
+	var sender, html *string
+	if email.From != "" {
+		sender = &email.From
+	}
+	if email.HTML != "" {
+		html = &email.HTML
+	}
+
+	validFor := int64(email.ValidFor.Seconds())
+	result, err := db.Exec(query, sender, string(recipients), email.Subject, email.Text, html, validFor, validFor)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// ExpireEmails moves the emails that passed their not_after before they could
+// be sent to the dead letter state, clearing their bodies.
+func ExpireEmails() (int64, error) {
+	const query = `UPDATE email_outbox SET status = ?, last_error = ?, next_attempt_at = CURRENT_TIMESTAMP, text_body = '', html_body = NULL WHERE status IN (?, ?) AND not_after <= CURRENT_TIMESTAMP`
+
+	result, err := db.Exec(query, EmailDead, "expired before it could be sent", EmailPending, EmailSending)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClaimDueEmails hands out up to limit emails that are due and holds them for
+// lease. Each claim counts as an attempt. Rows locked by another worker are
+// skipped, so several servers can share the outbox. Expired emails are never
+// handed out.
+func ClaimDueEmails(limit int, lease time.Duration) ([]OutboxEmail, error) {
+	const selectQuery = `SELECT id, sender, recipients, subject, text_body, html_body, status, attempts, last_error, next_attempt_at, not_after, sent_at, created_at FROM email_outbox WHERE status IN (?, ?) AND next_attempt_at <= CURRENT_TIMESTAMP AND (not_after IS NULL OR not_after > CURRENT_TIMESTAMP) ORDER BY next_attempt_at, id LIMIT ? FOR UPDATE SKIP LOCKED`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(selectQuery, EmailPending, EmailSending, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	emails, err := scanOutboxEmails(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(emails) == 0 {
+		return emails, nil
+	}
+
+	ids := make([]int64, len(emails))
+	for i, email := range emails {
+		ids[i] = email.Id
+	}
+
+	in, args := inClause(ids)
+	updateQuery := `UPDATE email_outbox SET status = ?, attempts = attempts + 1, next_attempt_at = CURRENT_TIMESTAMP + INTERVAL ? SECOND WHERE id IN ` + in
+	args = append([]any{EmailSending, int64(lease.Seconds())}, args...)
+	if _, err := tx.Exec(updateQuery, args...); err != nil {
+		return nil, err
+	}
+
+	for i := range emails {
+		emails[i].Status = EmailSending
+		emails[i].Attempts++
+	}
+
+	return emails, tx.Commit()
+}
+
+func MarkEmailSent(id int64) error {
+	const query = `UPDATE email_outbox SET status = ?, sent_at = CURRENT_TIMESTAMP, last_error = NULL, text_body = '', html_body = NULL WHERE id = ?`
+
+	_, err := db.Exec(query, EmailSent, id)
+	return err
+}
+
+// MarkEmailFailed schedules the next attempt in retryIn, or moves the email to
+// the dead letter state when dead is set.
+func MarkEmailFailed(id int64, sendErr string, retryIn time.Duration, dead bool) error {
+	const query = `UPDATE email_outbox SET status = ?, last_error = ?, next_attempt_at = CURRENT_TIMESTAMP + INTERVAL ? SECOND WHERE id = ?`
+
+	if dead {
+		return markEmailDead(id, sendErr)
+	}
+
+	_, err := db.Exec(query, EmailPending, sendErr, int64(retryIn.Seconds()), id)
+	return err
+}
+
+// markEmailDead clears the bodies of emails with a not_after along with the
+// status change, a dead magic link must not keep its token in the table. The
+// others keep them so that they can be requeued.
+func markEmailDead(id int64, sendErr string) error {
+	const query = `UPDATE email_outbox SET status = ?, last_error = ?, next_attempt_at = CURRENT_TIMESTAMP,
+	text_body = IF(not_after IS NULL, text_body, ''), html_body = IF(not_after IS NULL, html_body, NULL) WHERE id = ?`
+
+	_, err := db.Exec(query, EmailDead, sendErr, id)
+	return err
+}
+
+// RequeueEmail gives a dead email a fresh set of attempts, emails with a
+// not_after cannot be requeued.
+func RequeueEmail(id int64) error {
+	const query = `UPDATE email_outbox SET status = ?, attempts = 0, last_error = NULL, next_attempt_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ? AND not_after IS NULL`
+
+	result, err := db.Exec(query, EmailPending, id, EmailDead)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	var status string
+	if err := db.QueryRow(`SELECT status FROM email_outbox WHERE id = ?`, id).Scan(&status); err != nil {
+		return err
+	}
+
+	if status != EmailDead {
+		return ErrEmailNotDead
+	}
+
+	return ErrEmailExpired
+}
+
+// FetchOutboxEmails lists the outbox newest first, all statuses when status
+// is empty.
+func FetchOutboxEmails(status string, offset, limit int) ([]OutboxEmail, int64, error) {
+	where, args := "", []any{}
+	if status != "" {
+		where, args = ` WHERE status = ?`, append(args, status)
+	}
+
+	// the bodies are left out of listings
+	query := `SELECT id, sender, recipients, subject, '', NULL, status, attempts, last_error, next_attempt_at, not_after, sent_at, created_at FROM email_outbox` + where + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	emails, err := scanOutboxEmails(rows)
+	if err != nil {
+		return emails, 0, err
+	}
+
+	var count int64
+	err = db.QueryRow(`SELECT COUNT(*) FROM email_outbox`+where, args...).Scan(&count)
+
+	return emails, count, err
+}
+
+// DeleteOldEmails purges the emails in the given status, sent or dead, last
+// touched more than retention ago.
+func DeleteOldEmails(status string, retention time.Duration) (int64, error) {
+	const query = `DELETE FROM email_outbox WHERE status = ? AND updated_at < CURRENT_TIMESTAMP - INTERVAL ? SECOND`
+
+	result, err := db.Exec(query, status, int64(retention.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func scanOutboxEmails(rows *sql.Rows) ([]OutboxEmail, error) {
+	defer rows.Close()
+
+	emails := []OutboxEmail{}
+	for rows.Next() {
+		var (
+			email      OutboxEmail
+			sender     *string
+			recipients string
+			html       *string
+		)
+
+		err := rows.Scan(&email.Id, &sender, &recipients, &email.Subject, &email.Text, &html, &email.Status, &email.Attempts, &email.LastError, &email.NextAttemptAt, &email.NotAfter, &email.SentAt, &email.CreatedAt)
+		if err != nil {
+			return emails, err
+		}
+
+		if err := json.Unmarshal([]byte(recipients), &email.To); err != nil {
+			return emails, err
+		}
+		if sender != nil {
+			email.From = *sender
+		}
+		if html != nil {
+			email.HTML = *html
+		}
+
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdentityNotFound = errors.New("login method not found")
+	ErrIdentityTaken    = errors.New("login method is linked to another account")
+)
+
+// Identity is an account at a login provider linked to a user. Users are
+// found by provider and subject, the email is only what the provider last
+// reported and may change.
+type Identity struct {
+	Id         int64     `json:"id"`
+	Provider   string    `json:"provider"`
+	Email      *string   `json:"email"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+}
+
+// GetUserIdByIdentity returns 0 when no user has linked the identity.
+func GetUserIdByIdentity(provider, subject string) (int64, error) {
+	const query = `SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`
+
+	var userId int64
+	err := db.QueryRow(query, provider, subject).Scan(&userId)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return userId, err
+}
+
+// TouchIdentity records a sign in with the identity along with the email the
+// provider now reports.
+func TouchIdentity(provider, subject, email string) error {
+	const query = `UPDATE user_identities SET email = ?, last_used_at = CURRENT_TIMESTAMP WHERE provider = ? AND subject = ?`
+
+	_, err := db.Exec(query, nullString(email), provider, subject)
+	return err
+}
+
+// LinkIdentity links the identity to the user, linking it again to the same
+// user only touches it. It fails with ErrIdentityTaken when another user has
+// it.
+func LinkIdentity(userId int64, provider, subject, email string) error {
+	const query = `INSERT INTO user_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?)`
+
+	_, err := db.Exec(query, userId, provider, subject, nullString(email))
+	if err == nil || !isDuplicateEntry(err) {
+		return err
+	}
+
+	ownerId, err := GetUserIdByIdentity(provider, subject)
+	if err != nil {
+		return err
+	}
+	if ownerId != userId {
+		return ErrIdentityTaken
+	}
+
+	return TouchIdentity(provider, subject, email)
+}
+
+func FetchIdentities(userId int64) ([]Identity, error) {
+	const query = `SELECT id, provider, email, created_at, last_used_at FROM user_identities WHERE user_id = ? ORDER BY created_at, id`
+	identities := []Identity{}
+
+	rows, err := db.Query(query, userId)
+	if err != nil {
+		return identities, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity Identity
+		if err := rows.Scan(&identity.Id, &identity.Provider, &identity.Email, &identity.CreatedAt, &identity.LastUsedAt); err != nil {
+			return identities, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// UnlinkIdentity only removes identities of the given user, any other id is
+// reported as ErrIdentityNotFound.
+func UnlinkIdentity(userId, identityId int64) error {
+	const query = `DELETE FROM user_identities WHERE id = ? AND user_id = ?`
+
+	result, err := db.Exec(query, identityId, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+func nullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrImageNotFound = errors.New("image not found, upload it first")
+
+type Image struct {
+	Key                  string `json:"key"`
+	ThumbnailKey         string `json:"thumbnailKey"`
+	ContentType          string `json:"contentType"`
+	ThumbnailContentType string `json:"-"`
+	Width                int    `json:"width"`
+	Height               int    `json:"height"`
+	Size                 int    `json:"size"`
+}
+
+func CreateImage(image Image) error {
+	const query = `INSERT INTO images (image_key, thumbnail_key, content_type, thumbnail_content_type, width, height, size) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := db.Exec(query, image.Key, image.ThumbnailKey, image.ContentType, image.ThumbnailContentType, image.Width, image.Height, image.Size)
+	return err
+}
+
+// FetchImageContentType returns the content type detected on upload for an
+// image or thumbnail key, or sql.ErrNoRows for keys that were never uploaded.
+func FetchImageContentType(key string) (string, error) {
+	const query = `SELECT content_type FROM images WHERE image_key = ?
+				   UNION ALL
+				   SELECT thumbnail_content_type FROM images WHERE thumbnail_key = ?
+				   LIMIT 1`
+
+	var contentType string
+	err := db.QueryRow(query, key, key).Scan(&contentType)
+	return contentType, err
+}
+
+// FetchUnattachedImages returns up to limit images uploaded more than olderThan
+// ago that no product or variant uses.
+func FetchUnattachedImages(olderThan time.Duration, limit int) ([]Image, error) {
+	const query = `SELECT image_key, thumbnail_key FROM images AS T1
+				   WHERE created_at < CURRENT_TIMESTAMP - INTERVAL ? SECOND
+				   AND NOT EXISTS (SELECT 1 FROM product_images WHERE image_key = T1.image_key)
+				   LIMIT ?`
+
+	rows, err := db.Query(query, int64(olderThan.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []Image{}
+	for rows.Next() {
+		var image Image
+		if err := rows.Scan(&image.Key, &image.ThumbnailKey); err != nil {
+			return images, err
+		}
+
+		images = append(images, image)
+	}
+
+	return images, rows.Err()
+}
+
+// DeleteUnattachedImage removes the image unless a product started using it
+// since it was fetched, and reports whether it was removed.
+func DeleteUnattachedImage(key string) (bool, error) {
+	const query = `DELETE FROM images WHERE image_key = ? AND NOT EXISTS (SELECT 1 FROM product_images WHERE image_key = ?)`
+
+	result, err := db.Exec(query, key, key)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// setProductImages replaces the ordered images of a product, or of one of its
+// variants when variantId is set.
+func setProductImages(tx *sql.Tx, productId int64, variantId *int64, imageKeys []string) error {
+	if err := checkImagesExist(tx, imageKeys); err != nil {
+		return err
+	}
+
+	const deleteQuery = `DELETE FROM product_images WHERE product_id = ? AND variant_id <=> ?`
+	if _, err := tx.Exec(deleteQuery, productId, variantId); err != nil {
+		return err
+	}
+
+	const insertQuery = `INSERT INTO product_images (product_id, variant_id, image_key, position) VALUES (?, ?, ?, ?)`
+	for i, key := range imageKeys {
+		if _, err := tx.Exec(insertQuery, productId, variantId, key, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckImagesExist returns ErrImageNotFound unless every key is an uploaded
+// image.
+func CheckImagesExist(imageKeys []string) error {
+	return checkImagesExist(db, imageKeys)
+}
+
+func checkImagesExist(q querier, imageKeys []string) error {
+	unique := map[string]bool{}
+	for _, key := range imageKeys {
+		unique[key] = true
+	}
+
+	if len(unique) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(unique))
+	for key := range unique {
+		args = append(args, key)
+	}
+
+	query := `SELECT COUNT(*) FROM images WHERE image_key IN (` + placeholders(len(args)) + `)`
+
+	var count int
+	if err := q.QueryRow(query, args...).Scan(&count); err != nil {
+		return err
+	}
+
+	if count != len(unique) {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// fetchProductImages returns the ordered image keys of the given products, and
+// separately those of their variants.
+func fetchProductImages(q querier, productIds []int64) (map[int64][]string, map[int64][]string, error) {
+	productImages := map[int64][]string{}
+	variantImages := map[int64][]string{}
+
+	if len(productIds) == 0 {
+		return productImages, variantImages, nil
+	}
+
+	in, args := inClause(productIds)
+	query := `SELECT product_id, variant_id, image_key FROM product_images WHERE product_id IN ` + in + ` ORDER BY position, id`
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return productImages, variantImages, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			productId int64
+			variantId *int64
+			key       string
+		)
+
+		if err := rows.Scan(&productId, &variantId, &key); err != nil {
+			return productImages, variantImages, err
+		}
+
+		if variantId != nil {
+			variantImages[*variantId] = append(variantImages[*variantId], key)
+		} else {
+			productImages[productId] = append(productImages[productId], key)
+		}
+	}
+
+	return productImages, variantImages, rows.Err()
+}
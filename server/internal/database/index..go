@@ -3,10 +3,20 @@ package database
 import (
 	"database/sql"
 	"os"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 var db *sql.DB
 
+// querier is satisfied by both *sql.DB and *sql.Tx so that queries can be
+// shared between plain calls and transactions.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 func Init() {
 	db_env := os.Getenv("DB_URL")
 	if db_env == "" {
@@ -14,7 +24,15 @@ func Init() {
 		os.Exit(1)
 	}
 
-	if dbConn, err := sql.Open("mysql", db_env); err != nil {
+	cfg, err := mysql.ParseDSN(db_env)
+	if err != nil {
+		println("DB env is invalid")
+		os.Exit(1)
+	}
+	// timestamps are scanned straight into time.Time
+	cfg.ParseTime = true
+
+	if dbConn, err := sql.Open("mysql", cfg.FormatDSN()); err != nil {
 		println("Failed to connect to database")
 		os.Exit(1)
 	} else {
@@ -25,3 +43,20 @@ func Init() {
 func Close() {
 	db.Close()
 }
+
+// inClause returns the placeholders and arguments for an `IN (...)` clause
+// over the given ids.
+func inClause(ids []int64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	return "(" + placeholders(len(ids)) + ")", args
+}
+
+// isDuplicateEntry reports whether err was caused by a unique key violation.
+func isDuplicateEntry(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == 1062
+}
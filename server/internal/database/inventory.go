@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var ErrOutOfStock = errors.New("not enough stock available")
+
+// availableStock returns the stock of the variant when one is given, and of
+// the product itself otherwise. Products that are not active have none to
+// offer and report ErrProductUnavailable.
+func availableStock(q querier, productId int64, variantId *int64) (int64, error) {
+	var stock int64
+
+	status, err := productStatus(q, productId)
+	if err != nil {
+		return stock, err
+	}
+	if status != ProductActive {
+		return stock, ErrProductUnavailable
+	}
+
+	if variantId == nil {
+		const query = `SELECT stock FROM products WHERE id = ?`
+
+		err = q.QueryRow(query, productId).Scan(&stock)
+		return stock, err
+	}
+
+	const query = `SELECT stock FROM product_variants WHERE id = ?`
+
+	err = q.QueryRow(query, *variantId).Scan(&stock)
+	return stock, err
+}
+
+// decrementStock takes quantity units of the product, or of its variant, out
+// of stock. It is meant to run inside the order transaction so that
+// concurrent checkouts cannot oversell.
+func decrementStock(tx *sql.Tx, productId int64, variantId *int64, quantity int) error {
+	var (
+		result sql.Result
+		err    error
+	)
+
+	if variantId == nil {
+		const query = `UPDATE products SET stock = stock - ? WHERE id = ? AND stock >= ?`
+		result, err = tx.Exec(query, quantity, productId, quantity)
+	} else {
+		const query = `UPDATE product_variants SET stock = stock - ? WHERE id = ? AND stock >= ?`
+		result, err = tx.Exec(query, quantity, *variantId, quantity)
+	}
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrOutOfStock
+	}
+
+	return nil
+}
+
+// restockOrder puts the items of the order back in stock, it runs inside the
+// transaction that cancels or refunds the order. Since those statuses are
+// final an order is only ever restocked once.
+func restockOrder(tx *sql.Tx, orderId int64) error {
+	const productQuery = `UPDATE products AS T1
+	JOIN (SELECT product_id, SUM(quantity) AS quantity FROM order_items WHERE order_id = ? AND variant_id IS NULL GROUP BY product_id) AS T2 ON T2.product_id = T1.id
+	SET T1.stock = T1.stock + T2.quantity`
+	const variantQuery = `UPDATE product_variants AS T1
+	JOIN (SELECT variant_id, SUM(quantity) AS quantity FROM order_items WHERE order_id = ? AND variant_id IS NOT NULL GROUP BY variant_id) AS T2 ON T2.variant_id = T1.id
+	SET T1.stock = T1.stock + T2.quantity`
+
+	if _, err := tx.Exec(productQuery, orderId); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(variantQuery, orderId)
+	return err
+}
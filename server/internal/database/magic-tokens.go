@@ -1,45 +1,144 @@
 package database
 
-func CreateMagicToken(token string, userId int64) (int64, error) {
-	const query = `INSERT INTO magic_tokens (token, user_id) VALUES (?, ?)`
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
 
-	result, err := db.Exec(query, token, userId)
+var (
+	ErrMagicTokenInvalid = errors.New("magic token is invalid")
+	ErrMagicTokenExpired = errors.New("magic token has expired")
+	ErrMagicTokenUsed    = errors.New("magic token has already been used")
+	// ErrMagicTokenPending is returned while the link has not been clicked.
+	ErrMagicTokenPending = errors.New("magic token is not verified yet")
+)
+
+// NewMagicToken only holds hashes of the token and of the nonce. The token
+// only ever leaves in the email and the nonce in the response to the device
+// that asked for it.
+type NewMagicToken struct {
+	PublicId  string
+	TokenHash string
+	NonceHash string
+	UserId    int64
+	TTL       time.Duration
+}
+
+func CreateMagicToken(token NewMagicToken) error {
+	const query = `INSERT INTO magic_tokens (public_id, token_hash, nonce_hash, user_id, expires_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP + INTERVAL ? SECOND)`
+
+	_, err := db.Exec(query, token.PublicId, token.TokenHash, token.NonceHash, token.UserId, int64(token.TTL.Seconds()))
+	return err
+}
+
+// VerifyMagicToken marks the token as verified once its link is clicked. It
+// can be verified only once and only before it expires.
+func VerifyMagicToken(tokenHash string) error {
+	const query = `SELECT id, is_verified, expires_at > NOW() FROM magic_tokens WHERE token_hash = ?`
+
+	var (
+		tokenId    int64
+		isVerified bool
+		isValid    bool
+	)
+
+	err := db.QueryRow(query, tokenHash).Scan(&tokenId, &isVerified, &isValid)
+	if err == sql.ErrNoRows {
+		return ErrMagicTokenInvalid
+	}
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	return result.LastInsertId()
-}
+	if isVerified {
+		return ErrMagicTokenUsed
+	}
+	if !isValid {
+		return ErrMagicTokenExpired
+	}
+
+	const updateQuery = `UPDATE magic_tokens SET is_verified = true WHERE id = ? AND is_verified = false`
+	result, err := db.Exec(updateQuery, tokenId)
+	if err != nil {
+		return err
+	}
 
-func VerifyMagicToken(token string) (bool, error) {
-	const query = `SELECT EXISTS(SELECT 1 FROM magic_tokens WHERE token = ? AND is_verified = false)`
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-	var tokenExists bool
-	err := db.QueryRow(query, token).Scan(&tokenExists)
+	// the link was clicked twice at the same time
+	if affected == 0 {
+		return ErrMagicTokenUsed
+	}
 
-	return tokenExists, err
+	return nil
 }
 
-func RegisterMagicToken(token string) error {
-	const query = `UPDATE magic_tokens SET is_verified = true WHERE token = ?`
+// ConsumeMagicToken exchanges a verified token for the id of its user, exactly
+// once and only for the device holding its nonce. A wrong nonce is reported as
+// an invalid token. It also reports whether this token is the one that first
+// verified the user's email.
+func ConsumeMagicToken(publicId, nonceHash string) (int64, bool, error) {
+	const query = `SELECT id, user_id, is_verified, used_at IS NOT NULL, expires_at > NOW() FROM magic_tokens WHERE public_id = ? AND nonce_hash = ? FOR UPDATE`
 
-	_, err := db.Exec(query, token)
-	return err
-}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenId    int64
+		userId     int64
+		isVerified bool
+		isUsed     bool
+		isValid    bool
+	)
+
+	err = tx.QueryRow(query, publicId, nonceHash).Scan(&tokenId, &userId, &isVerified, &isUsed, &isValid)
+	if err == sql.ErrNoRows {
+		return 0, false, ErrMagicTokenInvalid
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if isUsed {
+		return 0, false, ErrMagicTokenUsed
+	}
+	if !isValid {
+		return 0, false, ErrMagicTokenExpired
+	}
+	if !isVerified {
+		return 0, false, ErrMagicTokenPending
+	}
+
+	const updateQuery = `UPDATE magic_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := tx.Exec(updateQuery, tokenId); err != nil {
+		return 0, false, err
+	}
 
-func CheckRegisteredMagicToken(tokenId int64) (bool, error) {
-	const query = `SELECT EXISTS(SELECT 1 FROM magic_tokens WHERE id = ? AND is_verified = true)`
-	var tokenVerified bool
+	verified, err := markEmailVerified(tx, userId)
+	if err != nil {
+		return 0, false, err
+	}
 
-	err := db.QueryRow(query, tokenId).Scan(&tokenVerified)
-	return tokenVerified, err
+	return userId, verified, tx.Commit()
 }
 
-func GetUserIdFromRegisteredMagicToken(tokenId int64) (int64, error) {
-	const query = `SELECT user_id FROM magic_tokens WHERE id = ? AND is_verified = true`
+// DeleteExpiredMagicTokens purges the tokens that expired more than retention
+// ago and returns how many were removed. Until then they are kept to tell an
+// expired or used token apart from an invalid one.
+func DeleteExpiredMagicTokens(retention time.Duration) (int64, error) {
+	const query = `DELETE FROM magic_tokens WHERE expires_at < CURRENT_TIMESTAMP - INTERVAL ? SECOND`
 
-	var userId int64
-	err := db.QueryRow(query, tokenId).Scan(&userId)
+	result, err := db.Exec(query, int64(retention.Seconds()))
+	if err != nil {
+		return 0, err
+	}
 
-	return userId, err
+	return result.RowsAffected()
 }
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderFulfilled = "fulfilled"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+var OrderStatuses = []string{OrderPending, OrderPaid, OrderFulfilled, OrderShipped, OrderDelivered, OrderCancelled, OrderRefunded}
+
+// orderTransitions lists, for every status, the statuses an order may move to
+// next. Cancelled and refunded orders are final.
+var orderTransitions = map[string][]string{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderFulfilled, OrderCancelled, OrderRefunded},
+	OrderFulfilled: {OrderShipped, OrderCancelled, OrderRefunded},
+	OrderShipped:   {OrderDelivered, OrderRefunded},
+	OrderDelivered: {OrderRefunded},
+	OrderCancelled: {},
+	OrderRefunded:  {},
+}
+
+type OrderTransitionError struct {
+	From string
+	To   string
+}
+
+func (e OrderTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+type OrderStatusChange struct {
+	FromStatus *string   `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	ActorId    int64     `json:"actorId"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func CanTransitionOrder(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateOrderStatus moves the order to the given status and records the change
+// in order_status_history. An OrderTransitionError is returned if the move is
+// not allowed from the order's current status. Cancelled and refunded orders
+// give their items back to the stock.
+func UpdateOrderStatus(orderId int64, status string, actorId int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const selectQuery = `SELECT status FROM orders WHERE id = ? FOR UPDATE`
+	var current string
+	if err := tx.QueryRow(selectQuery, orderId).Scan(&current); err != nil {
+		return err
+	}
+
+	if !CanTransitionOrder(current, status) {
+		return OrderTransitionError{From: current, To: status}
+	}
+
+	const updateQuery = `UPDATE orders SET status = ? WHERE id = ?`
+	if _, err := tx.Exec(updateQuery, status, orderId); err != nil {
+		return err
+	}
+
+	if status == OrderCancelled || status == OrderRefunded {
+		if err := restockOrder(tx, orderId); err != nil {
+			return err
+		}
+	}
+
+	if err := recordOrderStatus(tx, orderId, &current, status, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func FetchOrderStatusHistory(orderId int64) ([]OrderStatusChange, error) {
+	const query = `SELECT from_status, to_status, actor_id, created_at FROM order_status_history WHERE order_id = ? ORDER BY id`
+	history := []OrderStatusChange{}
+
+	rows, err := db.Query(query, orderId)
+	if err != nil {
+		return history, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change OrderStatusChange
+		if err := rows.Scan(&change.FromStatus, &change.ToStatus, &change.ActorId, &change.CreatedAt); err != nil {
+			return history, err
+		}
+
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+func recordOrderStatus(tx *sql.Tx, orderId int64, from *string, to string, actorId int64) error {
+	const query = `INSERT INTO order_status_history (order_id, from_status, to_status, actor_id) VALUES (?, ?, ?, ?)`
+
+	_, err := tx.Exec(query, orderId, from, to, actorId)
+	return err
+}
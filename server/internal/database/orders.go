@@ -0,0 +1,237 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+)
+
+var ErrEmptyCart = errors.New("cart is empty")
+
+type OrderSummary struct {
+	Id       int64   `json:"orderId"`
+	Subtotal float64 `json:"subtotal"`
+	Discount float64 `json:"discount"`
+	Total    float64 `json:"total"`
+}
+
+type OrderItem struct {
+	ProductId          int64              `json:"productId"`
+	VariantId          *int64             `json:"variantId"`
+	Name               string             `json:"name"`
+	Variant            *map[string]string `json:"variant"`
+	Price              float64            `json:"price"`
+	DiscountPercentage *float64           `json:"discountPercentage"`
+	Quantity           int                `json:"quantity"`
+}
+
+type Order struct {
+	Id        int64               `json:"id"`
+	Status    string              `json:"status"`
+	Subtotal  float64             `json:"subtotal"`
+	Discount  float64             `json:"discount"`
+	Total     float64             `json:"total"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Items     []OrderItem         `json:"items"`
+	History   []OrderStatusChange `json:"history,omitempty"`
+}
+
+// PlaceOrder snapshots every line of the cart into a new order and clears the
+// cart, all within a single transaction.
+func PlaceOrder(userId, cartId int64) (OrderSummary, error) {
+	var summary OrderSummary
+
+	tx, err := db.Begin()
+	if err != nil {
+		return summary, err
+	}
+	defer tx.Rollback()
+
+	cart, err := getCartDetails(tx, cartId)
+	if err != nil {
+		return summary, err
+	}
+
+	if len(cart.Products) == 0 {
+		return summary, ErrEmptyCart
+	}
+
+	for _, product := range cart.Products {
+		if product.Status != ProductActive {
+			return summary, ErrProductUnavailable
+		}
+
+		if product.Price == nil {
+			return summary, errors.New("price missing for product in cart")
+		}
+
+		lineTotal := *product.Price * float64(product.Quantity)
+		summary.Subtotal += lineTotal
+		if product.DiscountPercentage != nil {
+			summary.Discount += lineTotal * *product.DiscountPercentage / 100
+		}
+	}
+	summary.Subtotal = roundPrice(summary.Subtotal)
+	summary.Discount = roundPrice(summary.Discount)
+	summary.Total = roundPrice(summary.Subtotal - summary.Discount)
+
+	for _, product := range cart.Products {
+		if err := decrementStock(tx, product.Id, product.VariantId, product.Quantity); err != nil {
+			return summary, err
+		}
+	}
+
+	const orderQuery = `INSERT INTO orders (user_id, subtotal, discount, total) VALUES (?, ?, ?, ?)`
+	result, err := tx.Exec(orderQuery, userId, summary.Subtotal, summary.Discount, summary.Total)
+	if err != nil {
+		return summary, err
+	}
+
+	summary.Id, err = result.LastInsertId()
+	if err != nil {
+		return summary, err
+	}
+
+	const itemQuery = `INSERT INTO order_items (order_id, product_id, variant_id, name, variant, price, discount_percentage, quantity) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, product := range cart.Products {
+		var variant *string
+		if product.Variant != nil {
+			variantBytes, err := json.Marshal(*product.Variant)
+			if err != nil {
+				return summary, err
+			}
+			variantJSON := string(variantBytes)
+			variant = &variantJSON
+		}
+
+		_, err := tx.Exec(itemQuery, summary.Id, product.Id, product.VariantId, product.Name, variant, *product.Price, product.DiscountPercentage, product.Quantity)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	if err := recordOrderStatus(tx, summary.Id, nil, OrderPending, userId); err != nil {
+		return summary, err
+	}
+
+	const clearQuery = `DELETE FROM cart_items WHERE cart_id = ?`
+	if _, err := tx.Exec(clearQuery, cartId); err != nil {
+		return summary, err
+	}
+
+	return summary, tx.Commit()
+}
+
+func roundPrice(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func FetchOrders(userId int64, offset, limit int) ([]Order, int64, error) {
+	const query = `SELECT id, status, subtotal, discount, total, created_at FROM orders WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	orders := []Order{}
+
+	rows, err := db.Query(query, userId, limit, offset)
+	if err != nil {
+		return orders, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order Order
+		if err := rows.Scan(&order.Id, &order.Status, &order.Subtotal, &order.Discount, &order.Total, &order.CreatedAt); err != nil {
+			return orders, 0, err
+		}
+
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return orders, 0, err
+	}
+
+	orderIds := make([]int64, len(orders))
+	for i, order := range orders {
+		orderIds[i] = order.Id
+	}
+
+	items, err := fetchOrderItems(orderIds)
+	if err != nil {
+		return orders, 0, err
+	}
+
+	for i := range orders {
+		orders[i].Items = items[orders[i].Id]
+	}
+
+	count, err := UserOrdersCount(userId)
+
+	return orders, count, err
+}
+
+// FetchOrder only returns the order if it belongs to the given user, any other
+// order is reported as sql.ErrNoRows.
+func FetchOrder(userId, orderId int64) (Order, error) {
+	const query = `SELECT id, status, subtotal, discount, total, created_at FROM orders WHERE id = ? AND user_id = ?`
+
+	var order Order
+	err := db.QueryRow(query, orderId, userId).Scan(&order.Id, &order.Status, &order.Subtotal, &order.Discount, &order.Total, &order.CreatedAt)
+	if err != nil {
+		return order, err
+	}
+
+	items, err := fetchOrderItems([]int64{order.Id})
+	if err != nil {
+		return order, err
+	}
+	order.Items = items[order.Id]
+
+	order.History, err = FetchOrderStatusHistory(order.Id)
+
+	return order, err
+}
+
+func UserOrdersCount(userId int64) (int64, error) {
+	const query = `SELECT COUNT(*) FROM orders WHERE user_id = ?`
+	var count int64
+	err := db.QueryRow(query, userId).Scan(&count)
+
+	return count, err
+}
+
+func fetchOrderItems(orderIds []int64) (map[int64][]OrderItem, error) {
+	items := map[int64][]OrderItem{}
+	if len(orderIds) == 0 {
+		return items, nil
+	}
+
+	in, args := inClause(orderIds)
+	query := `SELECT order_id, product_id, variant_id, name, variant, price, discount_percentage, quantity FROM order_items WHERE order_id IN ` + in + ` ORDER BY id`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return items, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderId      int64
+			item         OrderItem
+			variantBytes *[]byte
+		)
+
+		if err := rows.Scan(&orderId, &item.ProductId, &item.VariantId, &item.Name, &variantBytes, &item.Price, &item.DiscountPercentage, &item.Quantity); err != nil {
+			return items, err
+		}
+
+		if variantBytes != nil {
+			if err := json.Unmarshal(*variantBytes, &item.Variant); err != nil {
+				return items, err
+			}
+		}
+
+		items[orderId] = append(items[orderId], item)
+	}
+
+	return items, rows.Err()
+}
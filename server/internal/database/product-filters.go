@@ -0,0 +1,331 @@
+package database
+
+import (
+	"sort"
+	"strings"
+)
+
+// effectivePrice is the price a product is listed at, the cheapest variant for
+// products that have variants.
+const effectivePrice = `COALESCE(price, (SELECT MIN(price) FROM product_variants WHERE product_id = products.id))`
+
+// effectiveDiscount is the best discount a product, or any of its variants,
+// is offered at.
+const effectiveDiscount = `COALESCE(discount_percentage, (SELECT MAX(discount_percentage) FROM product_variants WHERE product_id = products.id), 0)`
+
+// productSort orders products by key, falling back to their id for products
+// with the same key so that every product has a stable place to page from.
+type productSort struct {
+	key  string
+	desc bool
+	// text is set when key is a string rather than a number
+	text bool
+}
+
+func (s productSort) orderBy() string {
+	if s.desc {
+		return s.key + " DESC, id DESC"
+	}
+
+	return s.key + " ASC, id ASC"
+}
+
+// after returns the condition selecting the products that come after the
+// given key and id.
+func (s productSort) after(key any, id int64) (string, []any) {
+	op := ">"
+	if s.desc {
+		op = "<"
+	}
+
+	return "(" + s.key + " " + op + " ? OR (" + s.key + " = ? AND id " + op + " ?))", []any{key, key, id}
+}
+
+var productSorts = map[string]productSort{
+	// the default is on created_at rather than updated_at, which changes
+	// while clients page through and would skip or repeat products
+	"":         {key: "UNIX_TIMESTAMP(created_at)", desc: true},
+	"newest":   {key: "UNIX_TIMESTAMP(created_at)", desc: true},
+	"price":    {key: "COALESCE(" + effectivePrice + ", 0)"},
+	"-price":   {key: "COALESCE(" + effectivePrice + ", 0)", desc: true},
+	"name":     {key: "name", text: true},
+	"-name":    {key: "name", desc: true, text: true},
+	"discount": {key: effectiveDiscount, desc: true},
+}
+
+var ProductSorts = []string{"newest", "price", "-price", "name", "-name", "discount"}
+
+// ProductFilter narrows down the products that get listed, empty fields are
+// not filtered on.
+type ProductFilter struct {
+	// Category is the slug of a category, products in any of its descendant
+	// categories are included as well.
+	Category   string
+	MinPrice   *float64
+	MaxPrice   *float64
+	Discounted bool
+	InStock    bool
+	// Options maps a variant option to the values it may take, e.g. color to
+	// red or blue. Products need a single variant matching all of them.
+	Options map[string][]string
+}
+
+func (f ProductFilter) where(query *queryBuilder) {
+	conditions, args := f.conditions()
+	writeWhere(query, conditions, args)
+}
+
+// conditions always leave out products that are not active, drafts and
+// archived products are never listed.
+func (f ProductFilter) conditions() ([]string, []any) {
+	conditions := []string{"products.status = ?"}
+	args := []any{ProductActive}
+
+	if f.Category != "" {
+		conditions = append(conditions, `id IN (SELECT product_id FROM product_categories WHERE category_id IN (`+descendantCategoriesQuery+`))`)
+		args = append(args, f.Category)
+	}
+
+	if f.MinPrice != nil {
+		conditions = append(conditions, effectivePrice+" >= ?")
+		args = append(args, *f.MinPrice)
+	}
+
+	if f.MaxPrice != nil {
+		conditions = append(conditions, effectivePrice+" <= ?")
+		args = append(args, *f.MaxPrice)
+	}
+
+	if f.Discounted {
+		conditions = append(conditions, effectiveDiscount+" > 0")
+	}
+
+	if f.InStock {
+		conditions = append(conditions, `(stock > 0 OR EXISTS (SELECT 1 FROM product_variants WHERE product_id = products.id AND stock > 0))`)
+	}
+
+	if len(f.Options) > 0 {
+		names := make([]string, 0, len(f.Options))
+		for name := range f.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		condition := `EXISTS (SELECT 1 FROM product_variants AS V WHERE V.product_id = products.id`
+		for _, name := range names {
+			values := f.Options[name]
+			condition += ` AND EXISTS (SELECT 1 FROM product_variant_options WHERE variant_id = V.id AND name = ? AND value IN (` + placeholders(len(values)) + `))`
+
+			args = append(args, name)
+			for _, value := range values {
+				args = append(args, value)
+			}
+		}
+		condition += ")"
+
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, args
+}
+
+func writeWhere(query *queryBuilder, conditions []string, args []any) {
+	if len(conditions) > 0 {
+		query.write(" WHERE "+strings.Join(conditions, " AND "), args...)
+	}
+}
+
+// withoutOption returns a copy of the filter with the option removed.
+func (f ProductFilter) withoutOption(name string) ProductFilter {
+	options := map[string][]string{}
+	for key, values := range f.Options {
+		if key != name {
+			options[key] = values
+		}
+	}
+	f.Options = options
+
+	return f
+}
+
+type CategoryFacet struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type OptionFacet struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type PriceFacet struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// ProductFacets holds the counts a filter sidebar needs. Every facet is
+// counted with the rest of the filter applied but its own part left out, so
+// that the other values of a facet stay selectable.
+type ProductFacets struct {
+	Categories []CategoryFacet          `json:"categories"`
+	Options    map[string][]OptionFacet `json:"options"`
+	Price      PriceFacet               `json:"price"`
+	Discounted int64                    `json:"discounted"`
+	InStock    int64                    `json:"inStock"`
+}
+
+func FetchProductFacets(filter ProductFilter) (ProductFacets, error) {
+	var (
+		facets ProductFacets
+		err    error
+	)
+
+	withoutCategory := filter
+	withoutCategory.Category = ""
+	if facets.Categories, err = categoryFacets(withoutCategory); err != nil {
+		return facets, err
+	}
+
+	if facets.Options, err = optionFacets(filter, ""); err != nil {
+		return facets, err
+	}
+
+	for name := range filter.Options {
+		values, err := optionFacets(filter.withoutOption(name), name)
+		if err != nil {
+			return facets, err
+		}
+		facets.Options[name] = values[name]
+	}
+
+	withoutPrice := filter
+	withoutPrice.MinPrice, withoutPrice.MaxPrice = nil, nil
+	if facets.Price, err = priceFacet(withoutPrice); err != nil {
+		return facets, err
+	}
+
+	discounted := filter
+	discounted.Discounted = true
+	if facets.Discounted, err = ProductsCount(discounted); err != nil {
+		return facets, err
+	}
+
+	inStock := filter
+	inStock.InStock = true
+	facets.InStock, err = ProductsCount(inStock)
+
+	return facets, err
+}
+
+func categoryFacets(filter ProductFilter) ([]CategoryFacet, error) {
+	facets := []CategoryFacet{}
+
+	var query queryBuilder
+	query.write(`WITH RECURSIVE closure AS (
+		SELECT id AS ancestor_id, id AS category_id FROM categories
+		UNION ALL
+		SELECT T2.ancestor_id, T1.id FROM categories AS T1 JOIN closure AS T2 ON T1.parent_id = T2.category_id
+	)
+	SELECT T3.slug, T3.name, COUNT(DISTINCT T2.product_id)
+	FROM closure AS T1
+	JOIN product_categories AS T2 ON T2.category_id = T1.category_id
+	JOIN categories AS T3 ON T3.id = T1.ancestor_id
+	WHERE T2.product_id IN (SELECT id FROM products`)
+	filter.where(&query)
+	query.write(`) GROUP BY T3.id, T3.slug, T3.name ORDER BY T3.name`)
+
+	facetQuery, args := query.build()
+	rows, err := db.Query(facetQuery, args...)
+	if err != nil {
+		return facets, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet CategoryFacet
+		if err := rows.Scan(&facet.Slug, &facet.Name, &facet.Count); err != nil {
+			return facets, err
+		}
+
+		facets = append(facets, facet)
+	}
+
+	return facets, rows.Err()
+}
+
+// optionFacets counts the products per variant option value, only for the
+// given option name unless it is empty.
+func optionFacets(filter ProductFilter, name string) (map[string][]OptionFacet, error) {
+	facets := map[string][]OptionFacet{}
+
+	var query queryBuilder
+	query.write(`SELECT T2.name, T2.value, COUNT(DISTINCT T1.product_id)
+	FROM product_variants AS T1
+	JOIN product_variant_options AS T2 ON T2.variant_id = T1.id
+	WHERE T1.product_id IN (SELECT id FROM products`)
+	filter.where(&query)
+	query.write(")")
+	if name != "" {
+		query.write(" AND T2.name = ?", name)
+	}
+	query.write(" GROUP BY T2.name, T2.value ORDER BY T2.name, T2.value")
+
+	facetQuery, args := query.build()
+	rows, err := db.Query(facetQuery, args...)
+	if err != nil {
+		return facets, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			optionName string
+			facet      OptionFacet
+		)
+
+		if err := rows.Scan(&optionName, &facet.Value, &facet.Count); err != nil {
+			return facets, err
+		}
+
+		facets[optionName] = append(facets[optionName], facet)
+	}
+
+	return facets, rows.Err()
+}
+
+// ProductOptionNames returns the option names used by any variant, lower
+// cased as names are compared without regard to case.
+func ProductOptionNames() (map[string]bool, error) {
+	names := map[string]bool{}
+
+	rows, err := db.Query(`SELECT DISTINCT name FROM product_variant_options`)
+	if err != nil {
+		return names, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return names, err
+		}
+
+		names[strings.ToLower(name)] = true
+	}
+
+	return names, rows.Err()
+}
+
+func priceFacet(filter ProductFilter) (PriceFacet, error) {
+	var facet PriceFacet
+
+	var query queryBuilder
+	query.write(`SELECT MIN(` + effectivePrice + `), MAX(` + effectivePrice + `) FROM products`)
+	filter.where(&query)
+
+	facetQuery, args := query.build()
+	err := db.QueryRow(facetQuery, args...).Scan(&facet.Min, &facet.Max)
+
+	return facet, err
+}
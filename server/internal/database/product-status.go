@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+const (
+	ProductDraft    = "draft"
+	ProductActive   = "active"
+	ProductArchived = "archived"
+)
+
+var ProductStatuses = []string{ProductDraft, ProductActive, ProductArchived}
+
+var (
+	ErrProductUnavailable = errors.New("product is not available")
+	ErrProductNotArchived = errors.New("product is not archived")
+)
+
+// ArchiveProduct hides the product from the listing and search. Its rows are
+// kept so that carts and orders referring to it still resolve.
+func ArchiveProduct(productId int64) error {
+	const query = `UPDATE products SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	result, err := db.Exec(query, ProductArchived, productId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		exists, err := productExists(productId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+
+	return nil
+}
+
+// RestoreProduct makes an archived product active again.
+func RestoreProduct(productId int64) error {
+	const query = `UPDATE products SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`
+
+	result, err := db.Exec(query, ProductActive, productId, ProductArchived)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		exists, err := productExists(productId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+
+		return ErrProductNotArchived
+	}
+
+	return nil
+}
+
+// productStatus is the status of a product, sql.ErrNoRows if it does not
+// exist.
+func productStatus(q querier, productId int64) (string, error) {
+	const query = `SELECT status FROM products WHERE id = ?`
+
+	var status string
+	err := q.QueryRow(query, productId).Scan(&status)
+
+	return status, err
+}
+
+func productExists(productId int64) (bool, error) {
+	const query = `SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)`
+
+	var exists bool
+	err := db.QueryRow(query, productId).Scan(&exists)
+
+	return exists, err
+}
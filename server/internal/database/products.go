@@ -1,84 +1,198 @@
 package database
 
-import (
-	"encoding/json"
-	"fmt"
-)
-
 type NewProduct struct {
 	Name               string
 	Description        string
 	Price              float64
 	DiscountPercentage *float64
+	Stock              int64
+	// Status defaults to active when empty.
+	Status    string
+	ImageKeys []string
 }
 
 type NewProductWithVariants struct {
 	Name        string
 	Description string
+	Status      string
 	ImageKeys   []string
 	Variants    []map[string]any
 }
 
+// ProductPatch holds the fields of a product to update, nil fields are left as
+// they are. ClearDiscountPercentage removes the discount, which a nil
+// DiscountPercentage cannot express.
+type ProductPatch struct {
+	Name                    *string
+	Description             *string
+	Price                   *float64
+	DiscountPercentage      *float64
+	ClearDiscountPercentage bool
+	Stock                   *int64
+	Status                  *string
+	ImageKeys               *[]string
+	Variants                *[]map[string]any
+}
+
 type Product struct {
 	Id                 int64            `json:"id"`
 	Name               string           `json:"name"`
 	Description        *string          `json:"description"`
-	Price              *int64           `json:"price"`
-	DiscountPercentage *int64           `json:"discountPercentage"`
+	Price              *float64         `json:"price"`
+	DiscountPercentage *float64         `json:"discountPercentage"`
+	Stock              int64            `json:"stock"`
+	Status             string           `json:"status"`
 	ImageKeys          []string         `json:"imageKeys"`
 	Variants           []map[string]any `json:"variants"`
+
+	// Snippet is only set on search results.
+	Snippet *string `json:"snippet,omitempty"`
 }
 
 func CreateProduct(product NewProduct) error {
-	cols := []string{"name", "description", "price"}
-	values := []any{product.Name, product.Description, product.Price}
+	insert := insertInto("products").
+		value("name", product.Name).
+		value("description", product.Description).
+		value("price", product.Price).
+		value("stock", product.Stock)
 
 	if product.DiscountPercentage != nil {
-		cols = append(cols, "discount_percentage")
-		values = append(values, *product.DiscountPercentage)
+		insert.value("discount_percentage", *product.DiscountPercentage)
+	}
+	if product.Status != "" {
+		insert.value("status", product.Status)
 	}
 
-	query := `INSERT INTO products (`
-	for i, col := range cols {
-		if i < len(cols)-1 {
-			query += fmt.Sprintf("%s, ", col)
-		} else {
-			query += fmt.Sprintf("%s) ", col)
-		}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	query += "VALUES ("
-	for i := 0; i < len(values); i++ {
-		if i == len(values)-1 {
-			query += "?)"
-		} else {
-			query += "?, "
-		}
+	query, args := insert.build()
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	productId, err := result.LastInsertId()
+	if err != nil {
+		return err
 	}
 
-	query += ";"
-	_, err := db.Exec(query, values...)
+	if err := setProductImages(tx, productId, nil, product.ImageKeys); err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit()
 }
 
 func CreateProductWithVariants(product NewProductWithVariants) error {
-	const query = `INSERT INTO products (name, description, variants) VALUES (?, ?, ?)`
+	insert := insertInto("products").
+		value("name", product.Name).
+		value("description", product.Description)
+
+	if product.Status != "" {
+		insert.value("status", product.Status)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query, args := insert.build()
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	productId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	if err := setProductImages(tx, productId, nil, product.ImageKeys); err != nil {
+		return err
+	}
+
+	if err := insertProductVariants(tx, productId, product.Variants); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	variantsJSON, err := json.Marshal(product.Variants)
+func UpdateProduct(productId int64, patch ProductPatch) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	// updated_at is set explicitly as a variants-only change leaves the
+	// products row itself untouched
+	var query queryBuilder
+	query.write(`UPDATE products SET updated_at = CURRENT_TIMESTAMP`)
+
+	if patch.Name != nil {
+		query.write(", name = ?", *patch.Name)
+	}
+	if patch.Description != nil {
+		query.write(", description = ?", *patch.Description)
+	}
+	if patch.Price != nil {
+		query.write(", price = ?", *patch.Price)
+	}
+	if patch.DiscountPercentage != nil {
+		query.write(", discount_percentage = ?", *patch.DiscountPercentage)
+	} else if patch.ClearDiscountPercentage {
+		query.write(", discount_percentage = NULL")
+	}
+	if patch.Stock != nil {
+		query.write(", stock = ?", *patch.Stock)
+	}
+	if patch.Status != nil {
+		query.write(", status = ?", *patch.Status)
+	}
+	query.write(" WHERE id = ?", productId)
+
+	updateQuery, args := query.build()
+	if _, err := tx.Exec(updateQuery, args...); err != nil {
+		return err
+	}
+
+	if patch.ImageKeys != nil {
+		if err := setProductImages(tx, productId, nil, *patch.ImageKeys); err != nil {
+			return err
+		}
+	}
+
+	if patch.Variants != nil {
+		if err := syncProductVariants(tx, productId, *patch.Variants); err != nil {
+			return err
+		}
+	}
 
-	_, err = db.Exec(query, product.Name, product.Description, string(variantsJSON))
-	return err
+	return tx.Commit()
 }
 
-func FetchProducts(offset, limit int) ([]Product, int64, error) {
-	const query = `SELECT id, name, description, price, discount_percentage, variants FROM products ORDER BY updated_at DESC LIMIT ? OFFSET ?`
+// FetchProducts lists the products matching filter, ordered by one of
+// ProductSorts or by when they were last updated if sort is empty.
+//
+// Deprecated: offset paging skips or repeats products while they are being
+// updated, use FetchProductsPage instead.
+func FetchProducts(offset, limit int, filter ProductFilter, sort string) ([]Product, int64, error) {
 	products := []Product{}
 
-	rows, err := db.Query(query, limit, offset)
+	var query queryBuilder
+	query.write(`SELECT id, name, description, price, discount_percentage, stock, status FROM products`)
+	filter.where(&query)
+	query.write(" ORDER BY "+productSorts[sort].orderBy()+" LIMIT ? OFFSET ?", limit, offset)
+
+	selectQuery, args := query.build()
+	rows, err := db.Query(selectQuery, args...)
 	if err != nil {
 		return products, 0, err
 	}
@@ -86,65 +200,208 @@ func FetchProducts(offset, limit int) ([]Product, int64, error) {
 
 	for rows.Next() {
 		var product Product
-		var variants []byte
-		if err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.DiscountPercentage, &variants); err != nil {
+		if err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.DiscountPercentage, &product.Stock, &product.Status); err != nil {
 			return products, 0, err
 		}
 
-		if err := json.Unmarshal(variants, &product.Variants); err != nil {
-			return products, 0, err
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return products, 0, err
+	}
+
+	if err := attachDetails(products); err != nil {
+		return products, 0, err
+	}
+
+	count, err := ProductsCount(filter)
+
+	return products, count, err
+}
+
+// FetchProductsPage returns up to limit products matching filter, starting
+// after the given cursor, or from the first product if it is empty. The total
+// count is only computed when withCount is set.
+func FetchProductsPage(filter ProductFilter, sort, after string, limit int, withCount bool) (ProductPage, error) {
+	page := ProductPage{Products: []Product{}}
+
+	order, ok := productSorts[sort]
+	if !ok {
+		sort, order = "", productSorts[""]
+	}
+
+	conditions, args := filter.conditions()
+	if after != "" {
+		c, err := decodeCursor(after, sort, order.text)
+		if err != nil {
+			return page, err
 		}
 
-		products = append(products, product)
+		condition, cursorArgs := order.after(c.Key, c.Id)
+		conditions = append(conditions, condition)
+		args = append(args, cursorArgs...)
+	}
+
+	var query queryBuilder
+	query.write(`SELECT id, name, description, price, discount_percentage, stock, status, ` + order.key + ` FROM products`)
+	writeWhere(&query, conditions, args)
+	// one extra row tells whether there is a next page
+	query.write(" ORDER BY "+order.orderBy()+" LIMIT ?", limit+1)
+
+	selectQuery, selectArgs := query.build()
+	rows, err := db.Query(selectQuery, selectArgs...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	var lastKey any
+	for rows.Next() {
+		var (
+			product Product
+			textKey string
+			numKey  float64
+		)
+
+		key := any(&numKey)
+		if order.text {
+			key = &textKey
+		}
+
+		if err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.DiscountPercentage, &product.Stock, &product.Status, key); err != nil {
+			return page, err
+		}
+
+		if len(page.Products) == limit {
+			next := encodeCursor(cursor{Order: sort, Key: lastKey, Id: page.Products[limit-1].Id})
+			page.NextCursor = &next
+			break
+		}
+
+		if order.text {
+			lastKey = textKey
+		} else {
+			lastKey = numKey
+		}
+		page.Products = append(page.Products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
 	}
-	count, err := ProductsCount()
 
-	return products, count, nil
+	if err := attachDetails(page.Products); err != nil {
+		return page, err
+	}
+
+	if withCount {
+		count, err := ProductsCount(filter)
+		if err != nil {
+			return page, err
+		}
+		page.Count = &count
+	}
+
+	return page, nil
 }
 
 func FetchProduct(id int64) (Product, error) {
-	const query = `SELECT id, name, description, price, discount_percentage, variants FROM products WHERE id = ?`
+	const query = `SELECT id, name, description, price, discount_percentage, stock, status FROM products WHERE id = ?`
 
 	var product Product
-	var variants []byte
 
-	err := db.QueryRow(query, id).Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.DiscountPercentage, &variants)
+	err := db.QueryRow(query, id).Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.DiscountPercentage, &product.Stock, &product.Status)
 	if err != nil {
 		return product, err
 	}
 
-	err = json.Unmarshal(variants, &product.Variants)
-	return product, err
+	products := []Product{product}
+	err = attachDetails(products)
+
+	return products[0], err
 }
 
-func ProductHasVariants(id int64) (bool, error) {
-	const query = `SELECT JSON_LENGTH(variants) AS length FROM products WHERE id = ?`
+// FetchAllProducts returns up to limit products of any status whose id comes
+// after afterId, so that the whole catalog can be walked in batches.
+func FetchAllProducts(afterId int64, limit int) ([]Product, error) {
+	const query = `SELECT id, name, description, price, discount_percentage, stock, status FROM products WHERE id > ? ORDER BY id LIMIT ?`
+	products := []Product{}
+
+	rows, err := db.Query(query, afterId, limit)
+	if err != nil {
+		return products, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product Product
+		if err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.DiscountPercentage, &product.Stock, &product.Status); err != nil {
+			return products, err
+		}
+
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return products, err
+	}
 
-	var length int64
+	err = attachDetails(products)
 
-	err := db.QueryRow(query, id).Scan(&length)
+	return products, err
+}
+
+// attachDetails loads the images and variants of every product and sets them
+// in the flattened shape the product API returns.
+func attachDetails(products []Product) error {
+	productIds := make([]int64, len(products))
+	for i, product := range products {
+		productIds[i] = product.Id
+	}
+
+	variants, err := fetchProductVariants(db, productIds)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	if length > 0 {
-		return true, err
+	productImages, variantImages, err := fetchProductImages(db, productIds)
+	if err != nil {
+		return err
 	}
 
-	return false, err
+	for i := range products {
+		products[i].ImageKeys = []string{}
+		if imageKeys, ok := productImages[products[i].Id]; ok {
+			products[i].ImageKeys = imageKeys
+		}
+
+		products[i].Variants = []map[string]any{}
+		for _, variant := range variants[products[i].Id] {
+			if imageKeys, ok := variantImages[variant.Id]; ok {
+				variant.ImageKeys = imageKeys
+			}
+			products[i].Variants = append(products[i].Variants, variant.toMap())
+		}
+	}
+
+	return nil
 }
 
-func DeleteProduct(productId int64) error {
-	const query = `DELETE FROM products WHERE id = ?`
+func ProductHasVariants(id int64) (bool, error) {
+	const query = `SELECT EXISTS(SELECT 1 FROM product_variants WHERE product_id = products.id) FROM products WHERE id = ?`
+
+	var hasVariants bool
+	err := db.QueryRow(query, id).Scan(&hasVariants)
 
-	_, err := db.Exec(query, productId)
-	return err
+	return hasVariants, err
 }
 
-func ProductsCount() (int64, error) {
-	const query = `SELECT COUNT(*) FROM products`
+func ProductsCount(filter ProductFilter) (int64, error) {
+	var query queryBuilder
+	query.write(`SELECT COUNT(*) FROM products`)
+	filter.where(&query)
+
+	countQuery, args := query.build()
 	var count int64
-	err := db.QueryRow(query).Scan(&count)
+	err := db.QueryRow(countQuery, args...).Scan(&count)
 
 	return count, err
 }
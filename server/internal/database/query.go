@@ -0,0 +1,88 @@
+package database
+
+import (
+	"sort"
+	"strings"
+)
+
+// queryBuilder accumulates SQL text along with its arguments. Values are only
+// ever passed as arguments, never written into the SQL itself, so anything a
+// client sends stays out of the statement.
+type queryBuilder struct {
+	sql  strings.Builder
+	args []any
+}
+
+// write appends sql to the query. Every `?` in sql must have a matching value
+// in args.
+func (b *queryBuilder) write(sql string, args ...any) *queryBuilder {
+	b.sql.WriteString(sql)
+	b.args = append(b.args, args...)
+
+	return b
+}
+
+func (b *queryBuilder) build() (string, []any) {
+	return b.sql.String(), b.args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// insertBuilder builds an INSERT statement for a single row. Columns come from
+// the code, values are always placeholders.
+type insertBuilder struct {
+	table string
+	cols  []string
+	args  []any
+}
+
+func insertInto(table string) *insertBuilder {
+	return &insertBuilder{table: table}
+}
+
+func (b *insertBuilder) value(col string, value any) *insertBuilder {
+	b.cols = append(b.cols, col)
+	b.args = append(b.args, value)
+
+	return b
+}
+
+func (b *insertBuilder) build() (string, []any) {
+	var query queryBuilder
+	query.write("INSERT INTO " + b.table + " (" + strings.Join(b.cols, ", ") + ") ")
+	query.write("VALUES ("+placeholders(len(b.args))+")", b.args...)
+
+	return query.build()
+}
+
+// variantMatchQuery builds a query selecting the id of the product's variant
+// whose options are exactly the selected ones.
+func variantMatchQuery(productId int64, selected map[string]string) (string, []any) {
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	// keep the statement text stable for the same set of options
+	sort.Strings(names)
+
+	var query queryBuilder
+	query.write(`SELECT T1.id FROM product_variants AS T1 WHERE T1.product_id = ?`, productId)
+	query.write(` AND (SELECT COUNT(*) FROM product_variant_options WHERE variant_id = T1.id) = ?`, len(names))
+	query.write(` AND (SELECT COUNT(*) FROM product_variant_options WHERE variant_id = T1.id AND (`)
+
+	if len(names) == 0 {
+		query.write("FALSE")
+	}
+	for i, name := range names {
+		if i > 0 {
+			query.write(" OR ")
+		}
+		query.write("(name = ? AND value = ?)", name, selected[name])
+	}
+
+	query.write(")) = ? ORDER BY T1.position LIMIT 1", len(names))
+
+	return query.build()
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+var hostileInputs = []string{
+	`'`,
+	`"`,
+	"`",
+	`--`,
+	`/*`,
+	`'); DROP TABLE products;--`,
+}
+
+// checkParameterized fails when any of the hostile strings ended up in the SQL
+// text instead of the arguments.
+func checkParameterized(t *testing.T, query string, args []any, hostile ...string) {
+	t.Helper()
+
+	if want := strings.Count(query, "?"); want != len(args) {
+		t.Errorf("query has %d placeholders but %d args: %s", want, len(args), query)
+	}
+
+	for _, s := range hostile {
+		if strings.Contains(query, s) {
+			t.Errorf("query contains %q: %s", s, query)
+		}
+		if !slices.Contains(args, any(s)) {
+			t.Errorf("args do not contain %q: %v", s, args)
+		}
+	}
+}
+
+func TestVariantMatchQuery(t *testing.T) {
+	baseline, _ := variantMatchQuery(1, map[string]string{"color": "red", "size": "m"})
+
+	type test struct {
+		name     string
+		selected map[string]string
+		hostile  []string
+	}
+
+	var tests []test
+	for _, s := range hostileInputs {
+		tests = append(tests,
+			test{"key " + s, map[string]string{s: "red", "size": "m"}, []string{s}},
+			test{"value " + s, map[string]string{"color": s, "size": "m"}, []string{s}},
+			test{"key and value " + s, map[string]string{s: s, "size": s + s}, []string{s, s + s}},
+		)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := variantMatchQuery(1, tt.selected)
+
+			if query != baseline {
+				t.Errorf("query changed with the input\nwant: %s\ngot:  %s", baseline, query)
+			}
+			checkParameterized(t, query, args, tt.hostile...)
+		})
+	}
+}
+
+func TestVariantMatchQueryNoOptions(t *testing.T) {
+	query, args := variantMatchQuery(1, map[string]string{})
+
+	if !strings.Contains(query, "FALSE") {
+		t.Errorf("query without options should not match any option row: %s", query)
+	}
+	checkParameterized(t, query, args)
+}
+
+func TestInsertInto(t *testing.T) {
+	baseline, _ := insertInto("products").
+		value("name", "shirt").
+		value("description", "a shirt").
+		build()
+
+	for _, s := range hostileInputs {
+		t.Run(s, func(t *testing.T) {
+			query, args := insertInto("products").
+				value("name", s).
+				value("description", s+" "+s).
+				build()
+
+			if query != baseline {
+				t.Errorf("query changed with the input\nwant: %s\ngot:  %s", baseline, query)
+			}
+			checkParameterized(t, query, args, s, s+" "+s)
+		})
+	}
+}
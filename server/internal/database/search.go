@@ -0,0 +1,266 @@
+package database
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	snippetRadius = 60
+	// minTokenSize mirrors innodb_ft_min_token_size, shorter words are not in
+	// the full-text index.
+	minTokenSize = 3
+)
+
+// ftStopwords is InnoDB's default full-text stopword list. Like the words
+// shorter than minTokenSize, MATCH never finds them.
+var ftStopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "com": true, "de": true, "en": true, "for": true,
+	"from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true,
+	"where": true, "who": true, "will": true, "with": true, "und": true,
+	"www": true,
+}
+
+// searchTerms splits a search query into words, dropping the characters that
+// have a meaning in boolean full-text queries.
+func searchTerms(q string) []string {
+	var terms []string
+	for _, word := range strings.Fields(q) {
+		term := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, word)
+
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// booleanQuery requires every term while matching it as a prefix, so that
+// "blu shi" finds "blue shirt".
+func booleanQuery(terms []string) string {
+	query := make([]string, len(terms))
+	for i, term := range terms {
+		query[i] = "+" + term + "*"
+	}
+
+	return strings.Join(query, " ")
+}
+
+// searchMatch is the condition a product has to meet for a search and the
+// relevance it is ranked by.
+type searchMatch struct {
+	where         string
+	whereArgs     []any
+	relevance     string
+	relevanceArgs []any
+}
+
+// newSearchMatch requires every term. Terms the full-text index holds go
+// through MATCH, short ones are matched with LIKE instead, since MATCH would
+// find nothing for them. Stopwords are dropped unless the query has nothing
+// else, then they are matched with LIKE too. Terms only hold letters and
+// digits, so they never contain LIKE wildcards.
+func newSearchMatch(terms []string) searchMatch {
+	var indexed, short, stopwords []string
+	for _, term := range terms {
+		switch {
+		case ftStopwords[term]:
+			stopwords = append(stopwords, term)
+		case utf8.RuneCountInString(term) < minTokenSize:
+			short = append(short, term)
+		default:
+			indexed = append(indexed, term)
+		}
+	}
+	if len(indexed) == 0 && len(short) == 0 {
+		short = stopwords
+	}
+
+	var (
+		match      searchMatch
+		conditions []string
+		relevance  []string
+	)
+
+	if len(indexed) > 0 {
+		against := booleanQuery(indexed)
+		conditions = append(conditions, `MATCH(name, description) AGAINST (? IN BOOLEAN MODE)`)
+		match.whereArgs = append(match.whereArgs, against)
+		relevance = append(relevance, `MATCH(name) AGAINST (? IN BOOLEAN MODE) * 2 + MATCH(name, description) AGAINST (? IN BOOLEAN MODE)`)
+		match.relevanceArgs = append(match.relevanceArgs, against, against)
+	}
+
+	for _, term := range short {
+		pattern := "%" + term + "%"
+		conditions = append(conditions, `(name LIKE ? OR description LIKE ?)`)
+		match.whereArgs = append(match.whereArgs, pattern, pattern)
+		relevance = append(relevance, `(name LIKE ?) * 2 + (COALESCE(description, '') LIKE ?)`)
+		match.relevanceArgs = append(match.relevanceArgs, pattern, pattern)
+	}
+
+	match.where = strings.Join(conditions, " AND ")
+	match.relevance = "(" + strings.Join(relevance, " + ") + ")"
+
+	return match
+}
+
+// SearchProducts ranks products matching every term of q, giving matches in the
+// name twice the weight of matches in the description. Every product carries
+// a snippet with the matched words highlighted. Paging works the same way as
+// in FetchProductsPage.
+func SearchProducts(q, after string, limit int, withCount bool) (ProductPage, error) {
+	page := ProductPage{Products: []Product{}}
+
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		if withCount {
+			var count int64
+			page.Count = &count
+		}
+
+		return page, nil
+	}
+	match := newSearchMatch(terms)
+	matcher := termMatcher(terms)
+	relevance, relevanceArgs := match.relevance, match.relevanceArgs
+	order := "search:" + strings.Join(terms, " ")
+
+	var query queryBuilder
+	query.write(`SELECT id, name, description, price, discount_percentage, stock, status, `+relevance+` FROM products`, relevanceArgs...)
+	query.write(` WHERE `+match.where, match.whereArgs...)
+	query.write(` AND status = ?`, ProductActive)
+
+	if after != "" {
+		c, err := decodeCursor(after, order, false)
+		if err != nil {
+			return page, err
+		}
+		key := c.Key.(float64)
+
+		query.write(` AND (`+relevance+` < ?`, append(relevanceArgs, key)...)
+		query.write(` OR (`+relevance+` = ? AND id < ?))`, append(relevanceArgs, key, c.Id)...)
+	}
+
+	query.write(` ORDER BY `+relevance+` DESC, id DESC LIMIT ?`, append(relevanceArgs, limit+1)...)
+
+	selectQuery, args := query.build()
+	rows, err := db.Query(selectQuery, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	var lastRelevance float64
+	for rows.Next() {
+		var (
+			product   Product
+			relevance float64
+		)
+
+		if err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.DiscountPercentage, &product.Stock, &product.Status, &relevance); err != nil {
+			return page, err
+		}
+
+		if len(page.Products) == limit {
+			next := encodeCursor(cursor{Order: order, Key: lastRelevance, Id: page.Products[limit-1].Id})
+			page.NextCursor = &next
+			break
+		}
+
+		snippet := highlightSnippet(product.Name, product.Description, matcher)
+		product.Snippet = &snippet
+
+		lastRelevance = relevance
+		page.Products = append(page.Products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+
+	if err := attachDetails(page.Products); err != nil {
+		return page, err
+	}
+
+	if withCount {
+		var countQuery queryBuilder
+		countQuery.write(`SELECT COUNT(*) FROM products WHERE `+match.where, match.whereArgs...)
+		countQuery.write(` AND status = ?`, ProductActive)
+
+		var count int64
+		countSQL, countArgs := countQuery.build()
+		if err := db.QueryRow(countSQL, countArgs...).Scan(&count); err != nil {
+			return page, err
+		}
+		page.Count = &count
+	}
+
+	return page, nil
+}
+
+// termMatcher finds the words starting with one of the terms, it is built once
+// per search and shared by the snippets of every product.
+func termMatcher(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+
+	// RE2 has no lookbehind and its \b only knows ASCII, so the character
+	// before a word is matched too and the word itself is the first group
+	return regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])((?:` + strings.Join(quoted, "|") + `)[\p{L}\p{N}]*)`)
+}
+
+// highlightSnippet cuts a window of the description around the first word the
+// matcher finds, or of the name when the description has no match, and wraps
+// every matched word in <mark>. The rest of the text is HTML escaped so the
+// snippet is safe to render as is.
+func highlightSnippet(name string, description *string, matcher *regexp.Regexp) string {
+	text := name
+	if description != nil && *description != "" && (matcher.MatchString(*description) || !matcher.MatchString(name)) {
+		text = *description
+	}
+
+	runes := []rune(text)
+	start, end := 0, len(runes)
+
+	if loc := matcher.FindStringSubmatchIndex(text); loc != nil {
+		matchStart := len([]rune(text[:loc[2]]))
+		start = max(0, matchStart-snippetRadius)
+		end = min(len(runes), matchStart+snippetRadius)
+	} else {
+		end = min(len(runes), 2*snippetRadius)
+	}
+
+	window := string(runes[start:end])
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+
+	last := 0
+	for _, loc := range matcher.FindAllStringSubmatchIndex(window, -1) {
+		snippet.WriteString(html.EscapeString(window[last:loc[2]]))
+		snippet.WriteString("<mark>" + html.EscapeString(window[loc[2]:loc[3]]) + "</mark>")
+		last = loc[3]
+	}
+	snippet.WriteString(html.EscapeString(window[last:]))
+
+	if end < len(runes) {
+		snippet.WriteString("…")
+	}
+
+	return snippet.String()
+}
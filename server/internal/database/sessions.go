@@ -1,36 +1,214 @@
 package database
 
 import (
+	"errors"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Aaditya-23/server/internal/utils"
 )
 
-func CreateUserSession(userId int64) (string, time.Time, error) {
+const (
+	sessionIdSize       = 32
+	sessionPublicIdSize = 16
 
-	sessionId, err := utils.GenerateUUID()
-	expires := time.Now().Add(30 * 24 * time.Hour)
+	// the widths of the ip and user_agent columns, longer values fail the
+	// write in strict mode
+	sessionIPLength        = 64
+	sessionUserAgentLength = 512
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a signed in device. The id is the secret in the cookie and never
+// leaves the device it was issued to, the public id names the session
+// everywhere else.
+type Session struct {
+	Id         string     `json:"-"`
+	PublicId   string     `json:"id"`
+	UserId     int64      `json:"-"`
+	IP         *string    `json:"ip"`
+	UserAgent  *string    `json:"userAgent"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	Expires    time.Time  `json:"expiresAt"`
+	RotatedAt  *time.Time `json:"-"`
+}
+
+type NewSession struct {
+	UserId    int64
+	IP        string
+	UserAgent string
+	TTL       time.Duration
+}
+
+// CreateUserSession returns the id for the cookie and when it expires.
+func CreateUserSession(session NewSession) (string, time.Time, error) {
+	const query = `INSERT INTO sessions (id, public_id, user_id, ip, user_agent, expires, rotated_at) VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP + INTERVAL ? SECOND, CURRENT_TIMESTAMP)`
+
+	expires := time.Now().Add(session.TTL)
+
+	sessionId, err := utils.RandomToken(sessionIdSize)
 	if err != nil {
 		return sessionId, expires, err
 	}
 
-	const query = `INSERT INTO sessions (id, user_id, expires) VALUES (?, ?, ?)`
+	publicId, err := utils.RandomToken(sessionPublicIdSize)
+	if err != nil {
+		return sessionId, expires, err
+	}
 
-	_, err = db.Exec(query, sessionId, userId, expires)
+	ip, userAgent := sessionClient(session.IP, session.UserAgent)
+	_, err = db.Exec(query, sessionId, publicId, session.UserId, ip, userAgent, int64(session.TTL.Seconds()))
 	return sessionId, expires, err
 }
 
-func GetUserIdFromSession(sessionId string) (int64, error) {
-	const query = `SELECT user_id from sessions WHERE id = ? AND expires > Now()`
-	var userId int64
+// FetchSession returns the unexpired session with the id. An id that was just
+// rotated away still finds its session for grace, so that requests already in
+// flight with the old cookie do not sign the user out, the returned Id is then
+// the current one.
+func FetchSession(sessionId string, grace time.Duration) (Session, error) {
+	const query = `SELECT id, public_id, user_id, ip, user_agent, created_at, last_seen_at, expires, rotated_at FROM sessions WHERE expires > CURRENT_TIMESTAMP AND (id = ? OR (previous_id = ? AND rotated_at > CURRENT_TIMESTAMP - INTERVAL ? SECOND)) LIMIT 1`
 
-	err := db.QueryRow(query, sessionId).Scan(&userId)
-	return userId, err
+	var session Session
+	err := db.QueryRow(query, sessionId, sessionId, int64(grace.Seconds())).
+		Scan(&session.Id, &session.PublicId, &session.UserId, &session.IP, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt, &session.Expires, &session.RotatedAt)
+
+	return session, err
 }
 
+// TouchSession records the request and pushes the expiry ttl away from now.
+func TouchSession(sessionId, ip, userAgent string, ttl time.Duration) error {
+	const query = `UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, expires = CURRENT_TIMESTAMP + INTERVAL ? SECOND, ip = ?, user_agent = ? WHERE id = ?`
+
+	ipValue, userAgentValue := sessionClient(ip, userAgent)
+	_, err := db.Exec(query, int64(ttl.Seconds()), ipValue, userAgentValue, sessionId)
+	return err
+}
+
+// RotateSession gives the session a new id and returns it. It returns an
+// empty id when a concurrent request rotated the session first.
+func RotateSession(sessionId string) (string, error) {
+	const query = `UPDATE sessions SET previous_id = id, id = ?, rotated_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	newId, err := utils.RandomToken(sessionIdSize)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := db.Exec(query, newId, sessionId)
+	if err != nil {
+		return "", err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return "", err
+	}
+
+	return newId, nil
+}
+
+// rotateUserSessions has every session of the user rotated on its next
+// request.
+func rotateUserSessions(q querier, userId int64) error {
+	const query = `UPDATE sessions SET rotated_at = NULL WHERE user_id = ?`
+
+	_, err := q.Exec(query, userId)
+	return err
+}
+
+func FetchUserSessions(userId int64) ([]Session, error) {
+	const query = `SELECT id, public_id, user_id, ip, user_agent, created_at, last_seen_at, expires, rotated_at FROM sessions WHERE user_id = ? AND expires > CURRENT_TIMESTAMP ORDER BY last_seen_at DESC`
+	sessions := []Session{}
+
+	rows, err := db.Query(query, userId)
+	if err != nil {
+		return sessions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.Id, &session.PublicId, &session.UserId, &session.IP, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt, &session.Expires, &session.RotatedAt); err != nil {
+			return sessions, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession only revokes sessions of the given user, any other public id
+// is reported as ErrSessionNotFound.
+func RevokeSession(userId int64, publicId string) error {
+	const query = `DELETE FROM sessions WHERE public_id = ? AND user_id = ?`
+
+	result, err := db.Exec(query, publicId, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions signs the user out everywhere but the current session
+// and returns how many sessions were revoked.
+func RevokeOtherSessions(userId int64, currentPublicId string) (int64, error) {
+	const query = `DELETE FROM sessions WHERE user_id = ? AND public_id <> ?`
+
+	result, err := db.Exec(query, userId, currentPublicId)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DestroySession also takes the id a session was just rotated away from.
 func DestroySession(sessionId string) error {
-	const query = `DELETE FROM sessions WHERE id = ?`
+	const query = `DELETE FROM sessions WHERE id = ? OR previous_id = ?`
 
-	_, err := db.Exec(query, sessionId)
+	_, err := db.Exec(query, sessionId, sessionId)
 	return err
 }
+
+// DeleteExpiredSessions purges the expired sessions and returns how many were
+// removed.
+func DeleteExpiredSessions() (int64, error) {
+	const query = `DELETE FROM sessions WHERE expires < CURRENT_TIMESTAMP`
+
+	result, err := db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// sessionClient fits the ip and user agent sent by the client into their
+// columns.
+func sessionClient(ip, userAgent string) (*string, *string) {
+	return nullString(truncate(ip, sessionIPLength)), nullString(truncate(userAgent, sessionUserAgentLength))
+}
+
+// truncate cuts value to at most length characters, dropping invalid UTF-8
+// that the column would reject as well.
+func truncate(value string, length int) string {
+	value = strings.ToValidUTF8(value, "")
+	if utf8.RuneCountInString(value) <= length {
+		return value
+	}
+
+	return string([]rune(value)[:length])
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		length int
+		want   string
+	}{
+		{"short", "curl/8.0", 512, "curl/8.0"},
+		{"exact", "abcd", 4, "abcd"},
+		{"long", "abcdef", 4, "abcd"},
+		{"multibyte kept whole", "ääää", 2, "ää"},
+		{"invalid utf-8 dropped", "ab\xffcd", 4, "abcd"},
+		{"empty", "", 4, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.value, tt.length); got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.value, tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionClient(t *testing.T) {
+	ip, userAgent := sessionClient("", strings.Repeat("Mozilla/5.0 ", 100))
+
+	if ip != nil {
+		t.Errorf("empty ip should be stored as NULL, got %q", *ip)
+	}
+	if userAgent == nil || utf8.RuneCountInString(*userAgent) != sessionUserAgentLength {
+		t.Errorf("user agent should be cut to %d characters", sessionUserAgentLength)
+	}
+}
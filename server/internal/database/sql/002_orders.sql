@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE orders(
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    subtotal FLOAT NOT NULL,
+    discount FLOAT NOT NULL,
+    total FLOAT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE order_items(
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id),
+    product_id INT NOT NULL REFERENCES products(id),
+    name VARCHAR(255) NOT NULL,
+    variant JSON,
+    price FLOAT NOT NULL,
+    discount_percentage FLOAT,
+    quantity INT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE order_items;
+
+DROP TABLE orders;
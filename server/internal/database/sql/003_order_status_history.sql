@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE order_status_history(
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id),
+    from_status VARCHAR(32),
+    to_status VARCHAR(32) NOT NULL,
+    actor_id INT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE order_status_history;
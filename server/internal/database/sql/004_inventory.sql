@@ -0,0 +1,9 @@
+-- +goose Up
+-- Stock was not tracked before this migration, so there is no count to carry
+-- over. Every existing product and variant starts at 0 and is out of stock
+-- until an operator enters its real count, as the cart and order checks would
+-- otherwise sell units nobody has. Variants read a missing stock as 0.
+ALTER TABLE products ADD COLUMN stock INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE products DROP COLUMN stock;
@@ -0,0 +1,101 @@
+-- +goose Up
+CREATE TABLE product_variants(
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    position INT NOT NULL,
+    price FLOAT NOT NULL,
+    discount_percentage FLOAT,
+    stock INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE product_variant_options(
+    variant_id INT NOT NULL REFERENCES product_variants(id),
+    name VARCHAR(255) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    PRIMARY KEY (variant_id, name)
+);
+
+INSERT INTO product_variants (product_id, position, price, discount_percentage, stock)
+SELECT T1.id, T2.idx - 1, T2.price, T2.discount_percentage, COALESCE(T2.stock, 0)
+FROM products AS T1,
+JSON_TABLE(T1.variants, '$[*]' COLUMNS (
+    idx FOR ORDINALITY,
+    price FLOAT PATH '$.price',
+    discount_percentage FLOAT PATH '$.discountPercentage',
+    stock INT PATH '$.stock'
+)) AS T2;
+
+INSERT INTO product_variant_options (variant_id, name, value)
+SELECT T1.id, T3.name, JSON_UNQUOTE(JSON_EXTRACT(T2.variants, CONCAT('$[', T1.position, '].', JSON_QUOTE(T3.name))))
+FROM product_variants AS T1
+JOIN products AS T2 ON T1.product_id = T2.id,
+JSON_TABLE(JSON_KEYS(JSON_EXTRACT(T2.variants, CONCAT('$[', T1.position, ']'))), '$[*]' COLUMNS (
+    name VARCHAR(255) PATH '$'
+)) AS T3
+WHERE T3.name NOT IN ('price', 'discountPercentage', 'stock');
+
+ALTER TABLE cart_items ADD COLUMN variant_id INT REFERENCES product_variants(id);
+
+UPDATE cart_items AS T1
+JOIN product_variants AS T2 ON T1.product_id = T2.product_id
+SET T1.variant_id = T2.id
+WHERE T1.variant IS NOT NULL
+AND JSON_CONTAINS(T1.variant, (SELECT JSON_OBJECTAGG(name, value) FROM product_variant_options WHERE variant_id = T2.id))
+AND JSON_LENGTH(T1.variant) = (SELECT COUNT(*) FROM product_variant_options WHERE variant_id = T2.id);
+
+-- cart lines whose variant no longer exists cannot be kept in cart_items, they
+-- are moved aside instead of being lost
+CREATE TABLE unmatched_cart_items(
+    id INT NOT NULL PRIMARY KEY,
+    cart_id INT NOT NULL,
+    product_id INT NOT NULL,
+    variant JSON,
+    quantity INT NOT NULL,
+    moved_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO unmatched_cart_items (id, cart_id, product_id, variant, quantity)
+SELECT id, cart_id, product_id, variant, quantity FROM cart_items WHERE variant IS NOT NULL AND variant_id IS NULL;
+
+DELETE FROM cart_items WHERE variant IS NOT NULL AND variant_id IS NULL;
+
+ALTER TABLE cart_items DROP COLUMN variant;
+
+ALTER TABLE order_items ADD COLUMN variant_id INT REFERENCES product_variants(id);
+
+ALTER TABLE products DROP COLUMN variants;
+
+-- +goose Down
+ALTER TABLE products ADD COLUMN variants JSON NOT NULL DEFAULT ('[]');
+
+UPDATE products AS T1
+SET T1.variants = (
+    SELECT JSON_ARRAYAGG(JSON_MERGE_PATCH(
+        JSON_OBJECT('price', T2.price, 'discountPercentage', T2.discount_percentage, 'stock', T2.stock),
+        COALESCE((SELECT JSON_OBJECTAGG(name, value) FROM product_variant_options WHERE variant_id = T2.id), JSON_OBJECT())
+    ))
+    FROM product_variants AS T2
+    WHERE T2.product_id = T1.id
+)
+WHERE EXISTS (SELECT 1 FROM product_variants WHERE product_id = T1.id);
+
+ALTER TABLE order_items DROP COLUMN variant_id;
+
+ALTER TABLE cart_items ADD COLUMN variant JSON;
+
+UPDATE cart_items
+SET variant = (SELECT JSON_OBJECTAGG(name, value) FROM product_variant_options WHERE variant_id = cart_items.variant_id)
+WHERE variant_id IS NOT NULL;
+
+ALTER TABLE cart_items DROP COLUMN variant_id;
+
+INSERT INTO cart_items (id, cart_id, product_id, variant, quantity)
+SELECT id, cart_id, product_id, variant, quantity FROM unmatched_cart_items;
+
+DROP TABLE unmatched_cart_items;
+
+DROP TABLE product_variant_options;
+
+DROP TABLE product_variants;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'customer';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN role;
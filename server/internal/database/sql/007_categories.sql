@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE categories(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) UNIQUE NOT NULL,
+    parent_id INT REFERENCES categories(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE product_categories(
+    product_id INT NOT NULL REFERENCES products(id),
+    category_id INT NOT NULL REFERENCES categories(id),
+    PRIMARY KEY (product_id, category_id)
+);
+
+-- +goose Down
+DROP TABLE product_categories;
+
+DROP TABLE categories;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE products ADD FULLTEXT INDEX products_name_search (name);
+
+ALTER TABLE products ADD FULLTEXT INDEX products_search (name, description);
+
+-- +goose Down
+ALTER TABLE products DROP INDEX products_search;
+
+ALTER TABLE products DROP INDEX products_name_search;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE images(
+    image_key VARCHAR(255) NOT NULL PRIMARY KEY,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    size INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE product_images(
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    variant_id INT REFERENCES product_variants(id),
+    image_key VARCHAR(255) NOT NULL REFERENCES images(image_key),
+    position INT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE product_images;
+
+DROP TABLE images;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE products ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'active';
+
+CREATE INDEX products_status_idx ON products(status);
+
+-- +goose Down
+DROP INDEX products_status_idx ON products;
+
+ALTER TABLE products DROP COLUMN status;
@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE magic_tokens
+    ADD COLUMN token_hash CHAR(64),
+    ADD COLUMN expires_at TIMESTAMP NULL,
+    ADD COLUMN used_at TIMESTAMP NULL;
+
+UPDATE magic_tokens SET token_hash = SHA2(token, 256), expires_at = created_at + INTERVAL 15 MINUTE;
+
+ALTER TABLE magic_tokens
+    MODIFY token_hash CHAR(64) NOT NULL,
+    MODIFY expires_at TIMESTAMP NOT NULL,
+    DROP COLUMN token;
+
+CREATE UNIQUE INDEX magic_tokens_token_hash_idx ON magic_tokens(token_hash);
+
+CREATE INDEX magic_tokens_expires_at_idx ON magic_tokens(expires_at);
+
+-- +goose Down
+DROP INDEX magic_tokens_expires_at_idx ON magic_tokens;
+
+DROP INDEX magic_tokens_token_hash_idx ON magic_tokens;
+
+-- the raw tokens are gone, the hashes keep the column filled but match nothing
+ALTER TABLE magic_tokens ADD COLUMN token TEXT;
+
+UPDATE magic_tokens SET token = token_hash;
+
+ALTER TABLE magic_tokens
+    MODIFY token TEXT NOT NULL,
+    DROP COLUMN token_hash,
+    DROP COLUMN expires_at,
+    DROP COLUMN used_at;
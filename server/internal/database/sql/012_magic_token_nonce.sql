@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE magic_tokens
+    ADD COLUMN public_id VARCHAR(64),
+    ADD COLUMN nonce_hash CHAR(64);
+
+-- nobody holds a nonce for the existing tokens, they can no longer be exchanged
+UPDATE magic_tokens SET public_id = UUID(), nonce_hash = SHA2(UUID(), 256);
+
+ALTER TABLE magic_tokens
+    MODIFY public_id VARCHAR(64) NOT NULL,
+    MODIFY nonce_hash CHAR(64) NOT NULL;
+
+CREATE UNIQUE INDEX magic_tokens_public_id_idx ON magic_tokens(public_id);
+
+-- +goose Down
+DROP INDEX magic_tokens_public_id_idx ON magic_tokens;
+
+ALTER TABLE magic_tokens
+    DROP COLUMN public_id,
+    DROP COLUMN nonce_hash;
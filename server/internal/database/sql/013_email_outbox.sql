@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE email_outbox(
+    id SERIAL PRIMARY KEY,
+    sender VARCHAR(320),
+    recipients TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body MEDIUMTEXT NOT NULL,
+    html_body MEDIUMTEXT,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE INDEX email_outbox_due_idx ON email_outbox(status, next_attempt_at);
+
+-- +goose Down
+DROP TABLE email_outbox;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE user_identities(
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX user_identities_provider_subject_idx ON user_identities(provider, subject);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities(user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
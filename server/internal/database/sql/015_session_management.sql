@@ -0,0 +1,35 @@
+-- +goose Up
+ALTER TABLE sessions
+    ADD COLUMN public_id VARCHAR(64),
+    ADD COLUMN previous_id VARCHAR(255),
+    ADD COLUMN ip VARCHAR(64),
+    ADD COLUMN user_agent VARCHAR(512),
+    ADD COLUMN last_seen_at TIMESTAMP NULL,
+    ADD COLUMN rotated_at TIMESTAMP NULL;
+
+UPDATE sessions SET public_id = UUID(), last_seen_at = created_at, rotated_at = created_at;
+
+ALTER TABLE sessions
+    MODIFY public_id VARCHAR(64) NOT NULL,
+    MODIFY last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE UNIQUE INDEX sessions_public_id_idx ON sessions(public_id);
+
+CREATE INDEX sessions_previous_id_idx ON sessions(previous_id);
+
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
+
+-- +goose Down
+DROP INDEX sessions_user_id_idx ON sessions;
+
+DROP INDEX sessions_previous_id_idx ON sessions;
+
+DROP INDEX sessions_public_id_idx ON sessions;
+
+ALTER TABLE sessions
+    DROP COLUMN public_id,
+    DROP COLUMN previous_id,
+    DROP COLUMN ip,
+    DROP COLUMN user_agent,
+    DROP COLUMN last_seen_at,
+    DROP COLUMN rotated_at;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+
+-- existing users were welcomed when they signed up
+UPDATE users SET email_verified_at = created_at;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN email_verified_at;
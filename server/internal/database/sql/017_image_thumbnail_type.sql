@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE images ADD COLUMN thumbnail_content_type VARCHAR(64) NOT NULL DEFAULT 'image/jpeg';
+
+-- thumbnails are a jpeg, or a png for images that may be transparent
+UPDATE images SET thumbnail_content_type = 'image/png' WHERE thumbnail_key LIKE '%.png';
+
+CREATE UNIQUE INDEX images_thumbnail_key_idx ON images(thumbnail_key);
+
+CREATE INDEX product_images_image_key_idx ON product_images(image_key);
+
+-- +goose Down
+DROP INDEX product_images_image_key_idx ON product_images;
+
+DROP INDEX images_thumbnail_key_idx ON images;
+
+ALTER TABLE images DROP COLUMN thumbnail_content_type;
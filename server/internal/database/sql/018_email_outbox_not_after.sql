@@ -0,0 +1,8 @@
+-- +goose Up
+-- not_after is set on emails carrying a credential, like a magic link, that
+-- is useless once it expires. They go dead instead of being sent late, and
+-- their bodies are cleared when they do.
+ALTER TABLE email_outbox ADD COLUMN not_after TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE email_outbox DROP COLUMN not_after;
@@ -2,9 +2,17 @@ package database
 
 import "database/sql"
 
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
+var Roles = []string{RoleCustomer, RoleAdmin}
+
 type UserProfile struct {
 	Name  *string `json:"name"`
 	Email string  `json:"email"`
+	Role  string  `json:"role"`
 }
 
 func CheckUserByEmail(email string) (bool, error) {
@@ -44,10 +52,136 @@ func CreateUserAndReturnId(email string) (int, error) {
 }
 
 func FetchProfile(userId int64) (UserProfile, error) {
-	const query = `SELECT name, email FROM users WHERE id = ?`
+	const query = `SELECT name, email, role FROM users WHERE id = ?`
 	var profile UserProfile
 
-	err := db.QueryRow(query, userId).Scan(&profile.Name, &profile.Email)
+	err := db.QueryRow(query, userId).Scan(&profile.Name, &profile.Email, &profile.Role)
 
 	return profile, err
 }
+
+func GetUserRole(userId int64) (string, error) {
+	const query = `SELECT role FROM users WHERE id = ?`
+
+	var role string
+	err := db.QueryRow(query, userId).Scan(&role)
+	return role, err
+}
+
+// SetUserRole also has the sessions of the user rotated when the role
+// changes, so a session id known from before does not carry the new role.
+func SetUserRole(userId int64, role string) error {
+	const query = `UPDATE users SET role = ? WHERE id = ?`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, role, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		exists, err := userExists(userId)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return sql.ErrNoRows
+		}
+
+		return nil
+	}
+
+	if err := rotateUserSessions(tx, userId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MarkEmailVerified records that the user proved they own their email and
+// reports whether it was not verified before.
+func MarkEmailVerified(userId int64) (bool, error) {
+	return markEmailVerified(db, userId)
+}
+
+func markEmailVerified(q querier, userId int64) (bool, error) {
+	const query = `UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ? AND email_verified_at IS NULL`
+
+	result, err := q.Exec(query, userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func userExists(userId int64) (bool, error) {
+	const query = `SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`
+
+	var exists bool
+	err := db.QueryRow(query, userId).Scan(&exists)
+	return exists, err
+}
+
+// BootstrapAdmin sets up the first admin from the environment. When there is
+// no admin yet, the user with the given email is created if needed and made
+// an admin. Once an admin exists it does nothing, so an admin that was later
+// demoted is not promoted again on the next start.
+func BootstrapAdmin(email string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const adminQuery = `SELECT id FROM users WHERE role = ? LIMIT 1 FOR UPDATE`
+
+	var adminId int64
+	err = tx.QueryRow(adminQuery, RoleAdmin).Scan(&adminId)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	const query = `INSERT INTO users (email, role) VALUES (?, ?) ON DUPLICATE KEY UPDATE role = VALUES(role)`
+
+	result, err := tx.Exec(query, email, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	// 2 rows means an existing user was promoted
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 2 {
+		const idQuery = `SELECT id FROM users WHERE email = ?`
+
+		var userId int64
+		if err := tx.QueryRow(idQuery, email).Scan(&userId); err != nil {
+			return err
+		}
+
+		if err := rotateUserSessions(tx, userId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,314 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrVariantNotFound = errors.New("variant not found")
+
+// variantAttributes are the keys of a variant, as sent and returned by the
+// product API, that describe the SKU itself rather than one of its options.
+var variantAttributes = map[string]bool{
+	"price":              true,
+	"discountPercentage": true,
+	"stock":              true,
+	"imageKeys":          true,
+}
+
+type ProductVariant struct {
+	Id                 int64
+	ProductId          int64
+	Options            map[string]string
+	Price              float64
+	DiscountPercentage *float64
+	Stock              int64
+	ImageKeys          []string
+}
+
+func newProductVariant(variant map[string]any) ProductVariant {
+	productVariant := ProductVariant{Options: map[string]string{}, ImageKeys: []string{}}
+
+	for key, value := range variant {
+		switch key {
+		case "price":
+			productVariant.Price, _ = value.(float64)
+		case "discountPercentage":
+			if discountPercentage, ok := value.(float64); ok {
+				productVariant.DiscountPercentage = &discountPercentage
+			}
+		case "stock":
+			if stock, ok := value.(float64); ok {
+				productVariant.Stock = int64(stock)
+			}
+		case "imageKeys":
+			imageKeys, _ := value.([]any)
+			for _, imageKey := range imageKeys {
+				if key, ok := imageKey.(string); ok {
+					productVariant.ImageKeys = append(productVariant.ImageKeys, key)
+				}
+			}
+		default:
+			// validation only lets strings through, anything else is kept in
+			// its text form rather than stored as an empty value
+			productVariant.Options[key] = fmt.Sprint(value)
+		}
+	}
+
+	return productVariant
+}
+
+// toMap flattens the variant into the shape the product API has always used,
+// its options next to price, discountPercentage, stock and imageKeys.
+func (v ProductVariant) toMap() map[string]any {
+	variant := map[string]any{
+		"price":     v.Price,
+		"stock":     v.Stock,
+		"imageKeys": v.ImageKeys,
+	}
+
+	if v.DiscountPercentage != nil {
+		variant["discountPercentage"] = *v.DiscountPercentage
+	}
+
+	for key, value := range v.Options {
+		variant[key] = value
+	}
+
+	return variant
+}
+
+// sameOptions reports whether the variant has exactly the given options.
+// Names and values are compared without regard to case, the same way the
+// database collation compares them.
+func (v ProductVariant) sameOptions(options map[string]string) bool {
+	if len(v.Options) != len(options) {
+		return false
+	}
+
+	for key, value := range v.Options {
+		found := false
+		for otherKey, other := range options {
+			if strings.EqualFold(key, otherKey) && strings.EqualFold(value, other) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func insertProductVariants(tx *sql.Tx, productId int64, variants []map[string]any) error {
+	for i, variant := range variants {
+		if err := insertProductVariant(tx, productId, i, newProductVariant(variant)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertProductVariant(tx *sql.Tx, productId int64, position int, variant ProductVariant) error {
+	const variantQuery = `INSERT INTO product_variants (product_id, position, price, discount_percentage, stock) VALUES (?, ?, ?, ?, ?)`
+	const optionQuery = `INSERT INTO product_variant_options (variant_id, name, value) VALUES (?, ?, ?)`
+
+	result, err := tx.Exec(variantQuery, productId, position, variant.Price, variant.DiscountPercentage, variant.Stock)
+	if err != nil {
+		return err
+	}
+
+	variantId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for name, value := range variant.Options {
+		if _, err := tx.Exec(optionQuery, variantId, name, value); err != nil {
+			return err
+		}
+	}
+
+	return setProductImages(tx, productId, &variantId, variant.ImageKeys)
+}
+
+// syncProductVariants replaces the variants of a product. Variants whose
+// options already exist keep their id so that carts pointing at them stay
+// valid, variants that are gone are removed along with their cart items. An
+// existing variant keeps its discountPercentage, stock and imageKeys when the
+// key is left out, a null discountPercentage removes the discount.
+func syncProductVariants(tx *sql.Tx, productId int64, variants []map[string]any) error {
+	existing, err := fetchProductVariants(tx, []int64{productId})
+	if err != nil {
+		return err
+	}
+
+	const updateQuery = `UPDATE product_variants SET position = ?, price = ?, discount_percentage = ?, stock = ? WHERE id = ?`
+
+	kept := map[int64]bool{}
+	for i, variant := range variants {
+		productVariant := newProductVariant(variant)
+
+		found := false
+		for _, current := range existing[productId] {
+			if kept[current.Id] || !current.sameOptions(productVariant.Options) {
+				continue
+			}
+
+			if _, ok := variant["discountPercentage"]; !ok {
+				productVariant.DiscountPercentage = current.DiscountPercentage
+			}
+			if _, ok := variant["stock"]; !ok {
+				productVariant.Stock = current.Stock
+			}
+
+			if _, err := tx.Exec(updateQuery, i, productVariant.Price, productVariant.DiscountPercentage, productVariant.Stock, current.Id); err != nil {
+				return err
+			}
+
+			if _, ok := variant["imageKeys"]; ok {
+				if err := setProductImages(tx, productId, &current.Id, productVariant.ImageKeys); err != nil {
+					return err
+				}
+			}
+
+			kept[current.Id] = true
+			found = true
+			break
+		}
+
+		if !found {
+			if err := insertProductVariant(tx, productId, i, productVariant); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, current := range existing[productId] {
+		if kept[current.Id] {
+			continue
+		}
+
+		if err := deleteProductVariant(tx, current.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteProductVariant(tx *sql.Tx, variantId int64) error {
+	queries := []string{
+		`DELETE FROM cart_items WHERE variant_id = ?`,
+		`UPDATE order_items SET variant_id = NULL WHERE variant_id = ?`,
+		`DELETE FROM product_images WHERE variant_id = ?`,
+		`DELETE FROM product_variant_options WHERE variant_id = ?`,
+		`DELETE FROM product_variants WHERE id = ?`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query, variantId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fetchProductVariants(q querier, productIds []int64) (map[int64][]ProductVariant, error) {
+	variants := map[int64][]ProductVariant{}
+	if len(productIds) == 0 {
+		return variants, nil
+	}
+
+	in, args := inClause(productIds)
+	query := `SELECT id, product_id, price, discount_percentage, stock FROM product_variants WHERE product_id IN ` + in + ` ORDER BY product_id, position`
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return variants, err
+	}
+	defer rows.Close()
+
+	var variantIds []int64
+	for rows.Next() {
+		variant := ProductVariant{Options: map[string]string{}, ImageKeys: []string{}}
+		if err := rows.Scan(&variant.Id, &variant.ProductId, &variant.Price, &variant.DiscountPercentage, &variant.Stock); err != nil {
+			return variants, err
+		}
+
+		variantIds = append(variantIds, variant.Id)
+		variants[variant.ProductId] = append(variants[variant.ProductId], variant)
+	}
+	if err := rows.Err(); err != nil {
+		return variants, err
+	}
+
+	options, err := fetchVariantOptions(q, variantIds)
+	if err != nil {
+		return variants, err
+	}
+
+	for _, productVariants := range variants {
+		for i := range productVariants {
+			if variantOptions, ok := options[productVariants[i].Id]; ok {
+				productVariants[i].Options = variantOptions
+			}
+		}
+	}
+
+	return variants, nil
+}
+
+func fetchVariantOptions(q querier, variantIds []int64) (map[int64]map[string]string, error) {
+	options := map[int64]map[string]string{}
+	if len(variantIds) == 0 {
+		return options, nil
+	}
+
+	in, args := inClause(variantIds)
+	query := `SELECT variant_id, name, value FROM product_variant_options WHERE variant_id IN ` + in
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return options, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			variantId   int64
+			name, value string
+		)
+
+		if err := rows.Scan(&variantId, &name, &value); err != nil {
+			return options, err
+		}
+
+		if options[variantId] == nil {
+			options[variantId] = map[string]string{}
+		}
+		options[variantId][name] = value
+	}
+
+	return options, rows.Err()
+}
+
+// findVariantId resolves the selected options of a product to its SKU.
+func findVariantId(q querier, productId int64, selected map[string]string) (int64, error) {
+	query, args := variantMatchQuery(productId, selected)
+
+	var variantId int64
+	err := q.QueryRow(query, args...).Scan(&variantId)
+	if err == sql.ErrNoRows {
+		return 0, ErrVariantNotFound
+	}
+
+	return variantId, err
+}
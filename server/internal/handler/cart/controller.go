@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/mail"
+	"github.com/Aaditya-23/server/internal/notify"
 	"github.com/Aaditya-23/server/internal/utils"
 	v "github.com/aaditya-23/validator"
 )
@@ -86,17 +88,30 @@ func updateCart(w http.ResponseWriter, r *http.Request) {
 				}
 
 				if err := database.AddNewProductToCart(cartId, *body.ProductId, *body.Variant); err != nil {
+					if err == database.ErrOutOfStock || err == database.ErrVariantNotFound || err == database.ErrProductUnavailable {
+						utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+						return
+					}
+
 					println("an error occured while adding new product to the cart,", err.Error())
 					utils.ToJSON(w, 500, nil)
 					return
 				}
 			} else {
 				if err := database.AddNewProductToCart(cartId, *body.ProductId, nil); err != nil {
+					if err == database.ErrOutOfStock || err == database.ErrProductUnavailable {
+						utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+						return
+					}
+
 					println("an error occured while adding new product to the cart,", err.Error())
 					utils.ToJSON(w, 500, nil)
 					return
 				}
 			}
+
+			utils.ToJSON(w, 200, nil)
+			return
 		} else if err != nil {
 			println("an error occured while updating cart details", err.Error())
 			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
@@ -110,12 +125,22 @@ func updateCart(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if err := database.AddVariantProductToCart(cartId, *body.ProductId, *body.Variant); err != nil {
+				if err == database.ErrOutOfStock || err == database.ErrVariantNotFound || err == database.ErrProductUnavailable {
+					utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+					return
+				}
+
 				utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 				return
 			}
 		} else {
 			err := database.IncrementProductQuantityInCart(cartItemId)
 			if err != nil {
+				if err == database.ErrOutOfStock || err == database.ErrProductUnavailable {
+					utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+					return
+				}
+
 				utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 				return
 			}
@@ -138,6 +163,11 @@ func updateCart(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if err := database.DeleteVariantProductFromCart(cartId, *body.ProductId, *body.Variant); err != nil {
+				if err == database.ErrVariantNotFound || err == sql.ErrNoRows {
+					utils.ToJSON(w, 400, utils.ErrResponse{Error: "variant not found in cart"})
+					return
+				}
+
 				println("an error occured while deleting product from cart,", err.Error())
 				utils.ToJSON(w, 500, nil)
 				return
@@ -151,23 +181,59 @@ func updateCart(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	utils.ToJSON(w, 201, nil)
+	utils.ToJSON(w, 200, nil)
 }
 
 func order(w http.ResponseWriter, r *http.Request) {
 	userId := r.Context().Value("userId").(int64)
 	cartId, err := database.GetCartId(userId)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: "cart is empty"})
+			return
+		}
+
 		println("an error occured while placing order,", err.Error())
 		utils.ToJSON(w, 500, nil)
 		return
 	}
 
-	if err := database.Order(cartId); err != nil {
+	summary, err := database.PlaceOrder(userId, cartId)
+	if err != nil {
+		if err == database.ErrEmptyCart {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		if err == database.ErrOutOfStock || err == database.ErrVariantNotFound || err == database.ErrProductUnavailable {
+			utils.ToJSON(w, 409, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
 		println("an error occured while placing order,", err.Error())
 		utils.ToJSON(w, 500, nil)
 		return
 	}
 
-	utils.ToJSON(w, 200, nil)
+	// the order is placed either way, a failed confirmation is only logged
+	if err := sendOrderConfirmation(r, userId, summary.Id); err != nil {
+		println("an error occured while sending order confirmation,", err.Error())
+	}
+
+	utils.ToJSON(w, 201, summary)
+}
+
+func sendOrderConfirmation(r *http.Request, userId, orderId int64) error {
+	order, err := database.FetchOrder(userId, orderId)
+	if err != nil {
+		return err
+	}
+
+	profile, err := database.FetchProfile(userId)
+	if err != nil {
+		return err
+	}
+
+	locale := mail.MatchLocale(r.Header.Get("Accept-Language"))
+	return notify.OrderConfirmation(r.Context(), profile.Email, locale, order)
 }
@@ -0,0 +1,189 @@
+package category_handler
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/utils"
+	v "github.com/aaditya-23/validator"
+	"github.com/go-chi/chi/v5"
+)
+
+var slugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func validateSlug(slug string) error {
+	if !slugRegexp.MatchString(slug) {
+		return errors.New("slug must only contain lowercase letters, numbers and single hyphens")
+	}
+
+	return nil
+}
+
+func fetchCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := database.FetchCategories()
+	if err != nil {
+		println("an error occured while fetching categories,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Categories []database.Category `json:"categories"`
+	}{categories})
+}
+
+func createCategory(w http.ResponseWriter, r *http.Request) {
+	type ResBody struct {
+		Name     string `json:"name"`
+		Slug     string `json:"slug"`
+		ParentId *int64 `json:"parentId"`
+	}
+
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
+		return
+	}
+
+	errs := v.Struct(&body).
+		Fields(
+			v.String(&body.Name, "name").Min(1),
+			v.String(&body.Slug, "slug").Min(1).Refine(validateSlug),
+			v.Number(body.ParentId, "parentId").Optional().Refine(utils.AtLeast[int64]("parentId", 1)),
+		).
+		Parse()
+
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	categoryId, err := database.CreateCategory(database.NewCategory{
+		Name:     body.Name,
+		Slug:     body.Slug,
+		ParentId: body.ParentId,
+	})
+	if err != nil {
+		if err == database.ErrCategoryNotFound {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: "parent category not found"})
+			return
+		}
+
+		if err == database.ErrCategorySlugTaken {
+			utils.ToJSON(w, 409, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("an error occured while creating category,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 201, struct {
+		Id int64 `json:"id"`
+	}{categoryId})
+}
+
+func updateCategory(w http.ResponseWriter, r *http.Request) {
+	type ResBody struct {
+		Name         *string `json:"name"`
+		Slug         *string `json:"slug"`
+		ParentId     *int64  `json:"parentId"`
+		RemoveParent bool    `json:"removeParent"`
+	}
+
+	categoryId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
+		return
+	}
+
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
+		return
+	}
+
+	errs := v.Struct(&body).
+		Fields(
+			v.String(body.Name, "name").Optional().Refine(utils.MinLength("name", 1)),
+			v.String(body.Slug, "slug").Optional().Refine(validateSlug),
+			v.Number(body.ParentId, "parentId").Optional().Refine(utils.AtLeast[int64]("parentId", 1)),
+		).
+		Refine(func(rb ResBody) error {
+			if rb.RemoveParent && rb.ParentId != nil {
+				return errors.New("parentId and removeParent cannot be used together")
+			}
+
+			return nil
+		}).
+		Parse()
+
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	err = database.UpdateCategory(categoryId, database.CategoryPatch{
+		Name:         body.Name,
+		Slug:         body.Slug,
+		ParentId:     body.ParentId,
+		RemoveParent: body.RemoveParent,
+	})
+	if err != nil {
+		if err == database.ErrCategoryNotFound {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		if err == database.ErrCategoryCycle || err == database.ErrCategorySlugTaken {
+			utils.ToJSON(w, 409, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("an error occured while updating category,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, nil)
+}
+
+func deleteCategory(w http.ResponseWriter, r *http.Request) {
+	type ResBody struct {
+		CategoryId *int64 `json:"categoryId"`
+	}
+
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, nil)
+		return
+	}
+
+	errs := v.Number(body.CategoryId, "categoryId").Parse()
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	if err := database.DeleteCategory(*body.CategoryId); err != nil {
+		if err == database.ErrCategoryNotFound {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		if err == database.ErrCategoryHasChildren {
+			utils.ToJSON(w, 409, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("an error occured while deleting category,", err.Error())
+		utils.ToJSON(w, 500, nil)
+		return
+	}
+
+	utils.ToJSON(w, 200, nil)
+}
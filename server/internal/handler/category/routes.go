@@ -0,0 +1,24 @@
+package category_handler
+
+import (
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/handler/middlewares"
+	"github.com/go-chi/chi/v5"
+)
+
+func Mount() *chi.Mux {
+	// mounted with /category
+	r := chi.NewRouter()
+
+	r.Route("/", func(r chi.Router) {
+		r.Use(middlewares.AuthMiddleware)
+		r.Use(middlewares.RequireRole(database.RoleAdmin))
+		r.Post("/", createCategory)
+		r.Post("/delete", deleteCategory)
+		r.Patch("/{id}", updateCategory)
+	})
+
+	r.Get("/", fetchCategories)
+
+	return r
+}
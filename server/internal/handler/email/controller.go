@@ -0,0 +1,86 @@
+package email_handler
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultOutboxLimit = 20
+	maxOutboxLimit     = 100
+)
+
+// fetchOutbox lists the queued emails with their delivery status, filtered
+// with ?status=, e.g. ?status=dead for the dead letters.
+func fetchOutbox(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !slices.Contains(database.EmailStatuses, status) {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid status"})
+		return
+	}
+
+	offset, limit := 0, defaultOutboxLimit
+	var err error
+
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		offset, err = strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid offset"})
+			return
+		}
+	}
+
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxOutboxLimit {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: "limit must be between 1 and " + strconv.Itoa(maxOutboxLimit)})
+			return
+		}
+	}
+
+	emails, count, err := database.FetchOutboxEmails(status, offset, limit)
+	if err != nil {
+		println("an error occured while fetching the outbox,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Emails []database.OutboxEmail `json:"emails"`
+		Count  int64                  `json:"count"`
+	}{emails, count})
+}
+
+func retryEmail(w http.ResponseWriter, r *http.Request) {
+	emailId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
+		return
+	}
+
+	if err := database.RequeueEmail(emailId); err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "email not found"})
+			return
+		}
+
+		if err == database.ErrEmailNotDead || err == database.ErrEmailExpired {
+			utils.ToJSON(w, 409, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("an error occured while requeueing email,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Status string `json:"status"`
+	}{database.EmailPending})
+}
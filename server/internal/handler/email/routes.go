@@ -0,0 +1,21 @@
+package email_handler
+
+import (
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/handler/middlewares"
+	"github.com/go-chi/chi/v5"
+)
+
+func Mount() *chi.Mux {
+	// mounted with /email
+	r := chi.NewRouter()
+
+	r.Route("/", func(r chi.Router) {
+		r.Use(middlewares.AuthMiddleware)
+		r.Use(middlewares.RequireRole(database.RoleAdmin))
+		r.Get("/outbox", fetchOutbox)
+		r.Post("/outbox/{id}/retry", retryEmail)
+	})
+
+	return r
+}
@@ -2,6 +2,9 @@ package handler
 
 import (
 	cart_handler "github.com/Aaditya-23/server/internal/handler/cart"
+	category_handler "github.com/Aaditya-23/server/internal/handler/category"
+	email_handler "github.com/Aaditya-23/server/internal/handler/email"
+	order_handler "github.com/Aaditya-23/server/internal/handler/order"
 	product_handler "github.com/Aaditya-23/server/internal/handler/product"
 	user_handler "github.com/Aaditya-23/server/internal/handler/user"
 	"github.com/go-chi/chi/v5"
@@ -13,13 +16,16 @@ func Mount() *chi.Mux {
 
 	r.Use(cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST"},
+		AllowedMethods:   []string{"GET", "POST", "PATCH"},
 		AllowCredentials: true,
 	}).Handler)
 
 	r.Mount("/user", user_handler.Mount())
 	r.Mount("/product", product_handler.Mount())
 	r.Mount("/cart", cart_handler.Mount())
+	r.Mount("/order", order_handler.Mount())
+	r.Mount("/category", category_handler.Mount())
+	r.Mount("/email", email_handler.Mount())
 
 	return r
 }
@@ -5,7 +5,7 @@ import (
 	"database/sql"
 	"net/http"
 
-	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/auth"
 	"github.com/Aaditya-23/server/internal/utils"
 )
 
@@ -18,7 +18,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 		sessionId := cookie.Value
 
-		userId, err := database.GetUserIdFromSession(sessionId)
+		session, renewed, err := auth.ResumeSession(sessionId, ByIP(r), r.UserAgent())
 		if err != nil {
 			if err == sql.ErrNoRows {
 				utils.ToJSON(w, 401, utils.ErrResponse{Error: "login to complete this action"})
@@ -30,7 +30,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "userId", userId)
+		if renewed != nil {
+			utils.SetCookie(w, "session", renewed.Id, renewed.Expires)
+		}
+
+		// sessionId is the public id, the cookie value stays out of handlers
+		ctx := context.WithValue(r.Context(), "userId", session.UserId)
+		ctx = context.WithValue(ctx, "sessionId", session.PublicId)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
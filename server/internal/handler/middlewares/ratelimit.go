@@ -0,0 +1,149 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Aaditya-23/server/internal/ratelimit"
+	"github.com/Aaditya-23/server/internal/utils"
+)
+
+// maxPeekBytes bounds how much of a body is read to find a rate limit key.
+const maxPeekBytes = 64 << 10
+
+// RateLimitKey picks the bucket a request falls in, requests it returns an
+// empty key for are not limited by the rule.
+type RateLimitKey func(r *http.Request) string
+
+// RateLimitRule limits the requests sharing a key. The limit can be changed
+// without a deploy with RATE_LIMIT_<NAME>, e.g. RATE_LIMIT_AUTH_EMAIL=5/15m.
+type RateLimitRule struct {
+	Name  string
+	Limit ratelimit.Limit
+	Key   RateLimitKey
+}
+
+// RateLimit rejects requests going over any of the rules with a 429 and a
+// Retry-After header. The rules are checked in order and a rejected request
+// does not count against the rules after it.
+func RateLimit(rules ...RateLimitRule) func(http.Handler) http.Handler {
+	for i, rule := range rules {
+		name := "RATE_LIMIT_" + strings.ToUpper(rule.Name)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			println("ignoring "+name+",", err.Error())
+			continue
+		}
+		rules[i].Limit = limit
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rules {
+				key := rule.Key(r)
+				if key == "" {
+					continue
+				}
+
+				allowed, retryAfter, err := ratelimit.Take(r.Context(), rule.Name+":"+key, rule.Limit)
+				if err != nil {
+					// an unavailable store should not lock everyone out
+					println("error occured in rate limit middleware,", err.Error())
+					continue
+				}
+
+				if !allowed {
+					seconds := int(math.Ceil(retryAfter.Seconds()))
+					w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+					utils.ToJSON(w, 429, utils.ErrResponse{Error: "too many requests, try again later"})
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ByIP keys requests by client address. Behind proxies set
+// TRUST_PROXY_HEADERS=true and TRUSTED_PROXY_HOPS to the number of proxies in
+// front of the server, 1 by default. The client can write anything into
+// X-Forwarded-For, but each proxy appends the address it got the request
+// from, so the address is read that many entries from the right. Requests
+// that did not pass through every proxy fall back to the connection address.
+func ByIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if ip := forwardedFor(r.Header.Values("X-Forwarded-For"), trustedProxyHops()); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func trustedProxyHops() int {
+	if hops, err := strconv.Atoi(os.Getenv("TRUSTED_PROXY_HOPS")); err == nil && hops > 0 {
+		return hops
+	}
+
+	return 1
+}
+
+// forwardedFor returns the entry hops from the right of the X-Forwarded-For
+// headers, or an empty string when there are fewer entries or it is not an IP.
+func forwardedFor(headers []string, hops int) string {
+	var entries []string
+	for _, header := range headers {
+		for _, entry := range strings.Split(header, ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+
+	if len(entries) < hops {
+		return ""
+	}
+
+	ip := net.ParseIP(entries[len(entries)-hops])
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
+
+// ByJSONField keys requests by a string field of their JSON body, compared
+// case-insensitively. The body is left for the handler to read.
+func ByJSONField(field string) RateLimitKey {
+	return func(r *http.Request) string {
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBytes))
+		if err != nil {
+			return ""
+		}
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			return ""
+		}
+
+		value, _ := body[field].(string)
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestByIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     string
+		hops      string
+		forwarded []string
+		want      string
+	}{
+		{"proxy headers ignored by default", "", "", []string{"1.1.1.1"}, "10.0.0.1"},
+		{"no forwarded header", "true", "", nil, "10.0.0.1"},
+		{"single proxy", "true", "", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry is skipped", "true", "", []string{"1.1.1.1, 203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entries in several headers", "true", "", []string{"1.1.1.1", "2.2.2.2, 203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", "true", "2", []string{"1.1.1.1, 203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"fewer entries than proxies", "true", "2", []string{"203.0.113.7"}, "10.0.0.1"},
+		{"invalid hops count", "true", "x", []string{"1.1.1.1, 203.0.113.7"}, "203.0.113.7"},
+		{"entry is not an ip", "true", "", []string{"1.1.1.1, not-an-ip"}, "10.0.0.1"},
+		{"ipv6 entry", "true", "", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY_HEADERS", tt.trust)
+			t.Setenv("TRUSTED_PROXY_HOPS", tt.hops)
+
+			r := httptest.NewRequest("POST", "/user/auth/email", nil)
+			r.RemoteAddr = "10.0.0.1:52000"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := ByIP(r); got != tt.want {
+				t.Errorf("ByIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/utils"
+)
+
+// RequireRole only lets users with the given role through. It has to be used
+// after AuthMiddleware, which puts the userId in the request context.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId, ok := r.Context().Value("userId").(int64)
+			if !ok {
+				utils.ToJSON(w, 401, utils.ErrResponse{Error: "login to complete this action"})
+				return
+			}
+
+			userRole, err := database.GetUserRole(userId)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					utils.ToJSON(w, 401, utils.ErrResponse{Error: "login to complete this action"})
+					return
+				}
+
+				println("error occured in role middleware,", err.Error())
+				utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+				return
+			}
+
+			if userRole != role {
+				utils.ToJSON(w, 403, utils.ErrResponse{Error: "you are not allowed to complete this action"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
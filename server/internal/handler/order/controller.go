@@ -0,0 +1,130 @@
+package order_handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/utils"
+	v "github.com/aaditya-23/validator"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultOrdersLimit = 10
+	maxOrdersLimit     = 50
+)
+
+func fetchOrders(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
+
+	offset, limit := 0, defaultOrdersLimit
+	var err error
+
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		offset, err = strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid offset"})
+			return
+		}
+	}
+
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxOrdersLimit {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: "limit must be between 1 and " + strconv.Itoa(maxOrdersLimit)})
+			return
+		}
+	}
+
+	orders, count, err := database.FetchOrders(userId, offset, limit)
+	if err != nil {
+		println("an error occured while fetching orders,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Orders []database.Order `json:"orders"`
+		Count  int64            `json:"count"`
+	}{orders, count})
+}
+
+func fetchOrder(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
+
+	orderId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
+		return
+	}
+
+	order, err := database.FetchOrder(userId, orderId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "order not found"})
+			return
+		}
+
+		println("an error occured while fetching order,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Order database.Order `json:"order"`
+	}{order})
+}
+
+func updateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
+
+	type ResBody struct {
+		Status *string `json:"status"`
+	}
+
+	orderId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
+		return
+	}
+
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
+		return
+	}
+
+	errs := v.String(body.Status, "status").
+		Refine(utils.OneOf("status", database.OrderStatuses)).
+		Parse()
+
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	err = database.UpdateOrderStatus(orderId, *body.Status, userId)
+	if err != nil {
+		var transitionErr database.OrderTransitionError
+		if errors.As(err, &transitionErr) {
+			utils.ToJSON(w, 409, utils.ErrResponse{Error: transitionErr.Error()})
+			return
+		}
+
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "order not found"})
+			return
+		}
+
+		println("an error occured while updating order status,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Status string `json:"status"`
+	}{*body.Status})
+}
@@ -0,0 +1,20 @@
+package order_handler
+
+import (
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/handler/middlewares"
+	"github.com/go-chi/chi/v5"
+)
+
+func Mount() *chi.Mux {
+	// mounted with /order
+	r := chi.NewRouter()
+	r.Route("/", func(r chi.Router) {
+		r.Use(middlewares.AuthMiddleware)
+		r.Get("/", fetchOrders)
+		r.Get("/{id}", fetchOrder)
+		r.With(middlewares.RequireRole(database.RoleAdmin)).Post("/{id}/status", updateOrderStatus)
+	})
+
+	return r
+}
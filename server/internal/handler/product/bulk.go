@@ -0,0 +1,81 @@
+package product_handler
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Aaditya-23/server/internal/catalog"
+	"github.com/Aaditya-23/server/internal/utils"
+	v "github.com/aaditya-23/validator"
+)
+
+const maxImportBytes = 10 << 20
+
+var formatContentTypes = map[string]string{
+	catalog.FormatCSV:       "text/csv",
+	catalog.FormatJSONLines: "application/x-ndjson",
+}
+
+// bulkFormat reads the format query param, falling back to the content type
+// of the request body.
+func bulkFormat(r *http.Request) (string, []v.Error) {
+	format := r.URL.Query().Get("format")
+
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		for f, contentType := range formatContentTypes {
+			if contentType == mediaType {
+				format = f
+			}
+		}
+	}
+
+	return format, v.String(&format, "format").IsOneOf(catalog.Formats).Parse()
+}
+
+// importProducts creates products from a CSV or JSON lines body, see
+// catalog.Import. With ?dryRun=true the rows are only validated.
+func importProducts(w http.ResponseWriter, r *http.Request) {
+	format, errs := bulkFormat(r)
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	result, err := catalog.Import(r.Body, format, dryRun)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+		return
+	}
+
+	utils.ToJSON(w, 200, result)
+}
+
+func exportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = catalog.FormatCSV
+	}
+
+	errs := v.String(&format, "format").IsOneOf(catalog.Formats).Parse()
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := catalog.Export(&buf, format); err != nil {
+		println("error occured while exporting products", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="products.`+format+`"`)
+	w.Write(buf.Bytes())
+}
@@ -2,10 +2,11 @@ package product_handler
 
 import (
 	"database/sql"
-	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/Aaditya-23/server/internal/catalog"
 	"github.com/Aaditya-23/server/internal/database"
 	"github.com/Aaditya-23/server/internal/utils"
 	v "github.com/aaditya-23/validator"
@@ -13,17 +14,7 @@ import (
 )
 
 func createProduct(w http.ResponseWriter, r *http.Request) {
-
-	type ResBody struct {
-		Name               string   `json:"name"`
-		Description        string   `json:"description"`
-		Price              *float64 `json:"price"`
-		DiscountPercentage *float64 `json:"discountPercentage"`
-		// ImageKeys          []string         `json:"imageKeys"`
-		Variants *[]map[string]any `json:"variants"`
-	}
-
-	var body ResBody
+	var body catalog.Product
 
 	if err := utils.DecodeJSON(r, &body); err != nil {
 		println("error occured while decoding json", err.Error())
@@ -31,104 +22,47 @@ func createProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	errs := v.Struct(&body).
-		Fields(
-			v.String(&body.Name, "name").Min(1),
-			v.String(&body.Description, "description").Min(1),
-			v.Number(body.Price, "price").Optional(),
-			v.Number(body.DiscountPercentage, "discountPercentage").Optional(),
-			v.Slice(body.Variants, "variants").Optional().Refine(func(variants []map[string]any) error {
-				for _, v := range variants {
-					price, ok := v["price"]
-					if !ok {
-						return errors.New("every variant must have a price")
-					}
-					_, ok = price.(float64)
-					if !ok {
-						return errors.New("price must be of type integer")
-					}
-
-					discountPercentage, ok := v["discountPercentage"]
-					if ok {
-						if _, ok := discountPercentage.(float64); !ok {
-							return errors.New("discount percentage must be of type integer")
-						}
-					}
-
-					for key, value := range v {
-						if key == "price" || key == "discountPercentage" {
-							continue
-						}
-
-						if _, ok := value.(string); !ok {
-							return errors.New("value of variant-type must be of type string")
-						}
-					}
-				}
-
-				return nil
-			}),
-		).
-		Refine(func(rb ResBody) error {
-			if rb.Price == nil && rb.Variants == nil {
-				return errors.New("price or variants is required")
-			}
-
-			return nil
-		}).
-		Parse()
-
-	if len(errs) > 0 {
-		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+	if err := body.Validate(); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
 		return
 	}
 
-	if body.Price != nil {
-		err := database.CreateProduct(database.NewProduct{
-			Name:               body.Name,
-			Description:        body.Description,
-			Price:              *body.Price,
-			DiscountPercentage: body.DiscountPercentage,
-		})
-		if err != nil {
-			println("error occured while creating the product", err.Error())
-			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+	if err := body.Create(); err != nil {
+		if err == database.ErrImageNotFound {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
 			return
 		}
 
-		utils.ToJSON(w, 201, nil)
-		return
-	}
-
-	if err := database.CreateProductWithVariants(database.NewProductWithVariants{
-		Name:        body.Name,
-		Description: body.Description,
-		Variants:    *body.Variants,
-	}); err != nil {
-		println("error occured while creating a product with variants", err.Error())
+		println("error occured while creating the product", err.Error())
 		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	utils.ToJSON(w, 201, "done")
+	utils.ToJSON(w, 201, nil)
 }
 
+// fetchProducts serves the deprecated /{offset}-{limit} route, listProducts
+// replaces it.
 func fetchProducts(w http.ResponseWriter, r *http.Request) {
 	offsetParam := chi.URLParam(r, "offset")
 	limitParam := chi.URLParam(r, "limit")
 
 	offset, err := strconv.Atoi(offsetParam)
-	if err != nil {
+	if err != nil || offset < 0 {
 		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
 		return
 	}
 	limit, err := strconv.Atoi(limitParam)
-	if err != nil {
+	if err != nil || limit < 1 {
 		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
 		return
 	}
+	limit = min(limit, maxProductsLimit)
+
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</product>; rel="successor-version"`)
 
-	products, count, err := database.FetchProducts(offset, limit)
+	products, count, err := database.FetchProducts(offset, limit, database.ProductFilter{}, "")
 	if err != nil {
 		println("error occured while fetching products", err.Error())
 		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
@@ -165,9 +99,17 @@ func fetchProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// archived products still resolve for the carts and orders linking to
+	// them, drafts are not published yet
+	if product.Status == database.ProductDraft {
+		utils.ToJSON(w, 200, Response{nil})
+		return
+	}
+
 	utils.ToJSON(w, 200, Response{Product: &product})
 }
 
+// deleteProduct only archives the product, see database.ArchiveProduct.
 func deleteProduct(w http.ResponseWriter, r *http.Request) {
 	type ResBody struct {
 		ProductId *int64 `json:"productId"`
@@ -185,11 +127,281 @@ func deleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := database.DeleteProduct(*body.ProductId); err != nil {
-		println("an error occured while deleting the product,", err.Error())
+	if err := database.ArchiveProduct(*body.ProductId); err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "product not found"})
+			return
+		}
+
+		println("an error occured while archiving the product,", err.Error())
 		utils.ToJSON(w, 500, nil)
 		return
 	}
 
 	utils.ToJSON(w, 200, nil)
 }
+
+func restoreProduct(w http.ResponseWriter, r *http.Request) {
+	productId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
+		return
+	}
+
+	if err := database.RestoreProduct(productId); err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "product not found"})
+			return
+		}
+
+		if err == database.ErrProductNotArchived {
+			utils.ToJSON(w, 409, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("an error occured while restoring the product,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, nil)
+}
+
+func updateProduct(w http.ResponseWriter, r *http.Request) {
+	type ResBody struct {
+		Name               *string                 `json:"name"`
+		Description        *string                 `json:"description"`
+		Price              *float64                `json:"price"`
+		DiscountPercentage utils.Nullable[float64] `json:"discountPercentage"`
+		Stock              *int64                  `json:"stock"`
+		Status             *string                 `json:"status"`
+		ImageKeys          *[]string               `json:"imageKeys"`
+		Variants           *[]map[string]any       `json:"variants"`
+	}
+
+	productId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
+		return
+	}
+
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		println("error occured while decoding json", err.Error())
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
+		return
+	}
+
+	errs := v.Struct(&body).
+		Fields(
+			v.String(body.Name, "name").Optional().Refine(utils.MinLength("name", 1)),
+			v.String(body.Description, "description").Optional().Refine(utils.MinLength("description", 1)),
+			v.Number(body.Price, "price").Optional(),
+			v.Number(body.DiscountPercentage.Value, "discountPercentage").Optional(),
+			v.Number(body.Stock, "stock").Optional().Refine(utils.AtLeast[int64]("stock", 0)),
+			v.String(body.Status, "status").Optional().Refine(utils.OneOf("status", database.ProductStatuses)),
+			v.Slice(body.ImageKeys, "imageKeys").Optional().Refine(utils.MaxItems[string]("imageKeys", catalog.MaxProductImages)),
+			v.Slice(body.Variants, "variants").Optional().Refine(catalog.ValidateVariants),
+		).
+		Parse()
+
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	product, err := database.FetchProduct(productId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "product not found"})
+			return
+		}
+
+		println("an error occured while fetching product from the database,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	hasPrice := body.Price != nil || product.Price != nil
+	hasVariants := len(product.Variants) > 0
+	if body.Variants != nil {
+		hasVariants = len(*body.Variants) > 0
+	}
+
+	if !hasPrice && !hasVariants {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "price or variants is required"})
+		return
+	}
+
+	err = database.UpdateProduct(productId, database.ProductPatch{
+		Name:               body.Name,
+		Description:        body.Description,
+		Price:              body.Price,
+		DiscountPercentage: body.DiscountPercentage.Value,
+		// null removes the discount, leaving it out keeps it
+		ClearDiscountPercentage: body.DiscountPercentage.Set && body.DiscountPercentage.Value == nil,
+		Stock:                   body.Stock,
+		Status:                  body.Status,
+		ImageKeys:               body.ImageKeys,
+		Variants:                body.Variants,
+	})
+	if err != nil {
+		if err == database.ErrImageNotFound {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("an error occured while updating the product,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	product, err = database.FetchProduct(productId)
+	if err != nil {
+		println("an error occured while fetching product from the database,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Product database.Product `json:"product"`
+	}{product})
+}
+
+func listProducts(w http.ResponseWriter, r *http.Request) {
+	after, limit, withCount, err := parsePage(r)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+		return
+	}
+
+	var optionNames map[string]bool
+	if hasOptionParams(r) {
+		optionNames, err = database.ProductOptionNames()
+		if err != nil {
+			println("error occured while fetching product option names", err.Error())
+			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
+		}
+	}
+
+	filter, err := parseProductFilter(r, optionNames)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+		return
+	}
+
+	sort := r.URL.Query().Get("sort")
+	if sort != "" {
+		errs := v.String(&sort, "sort").IsOneOf(database.ProductSorts).Parse()
+		if len(errs) > 0 {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+			return
+		}
+	}
+
+	page, err := database.FetchProductsPage(filter, sort, after, limit, withCount)
+	if err != nil {
+		if err == database.ErrInvalidCursor {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("error occured while fetching products", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	// facets cost several queries, so they are only computed when asked for
+	var facets *database.ProductFacets
+	if r.URL.Query().Get("includeFacets") == "true" {
+		productFacets, err := database.FetchProductFacets(filter)
+		if err != nil {
+			println("error occured while fetching product facets", err.Error())
+			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
+		}
+		facets = &productFacets
+	}
+
+	utils.ToJSON(w, 200, struct {
+		database.ProductPage
+		Facets *database.ProductFacets `json:"facets,omitempty"`
+	}{page, facets})
+}
+
+func setProductCategories(w http.ResponseWriter, r *http.Request) {
+	type ResBody struct {
+		CategoryIds *[]int64 `json:"categoryIds"`
+	}
+
+	productId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
+		return
+	}
+
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
+		return
+	}
+
+	errs := v.Slice(body.CategoryIds, "categoryIds").Parse()
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
+		return
+	}
+
+	if _, err := database.FetchProduct(productId); err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "product not found"})
+			return
+		}
+
+		println("an error occured while fetching product from the database,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	if err := database.SetProductCategories(productId, *body.CategoryIds); err != nil {
+		if err == database.ErrCategoryNotFound {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("an error occured while setting product categories,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, nil)
+}
+
+func searchProducts(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "q is required"})
+		return
+	}
+
+	after, limit, withCount, err := parsePage(r)
+	if err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+		return
+	}
+
+	page, err := database.SearchProducts(q, after, limit, withCount)
+	if err != nil {
+		if err == database.ErrInvalidCursor {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("error occured while searching products", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, page)
+}
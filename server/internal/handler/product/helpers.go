@@ -0,0 +1,117 @@
+package product_handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aaditya-23/server/internal/database"
+)
+
+const (
+	defaultProductsLimit = 20
+	maxProductsLimit     = 100
+)
+
+// optionParamPrefix marks a query param as a variant option filter, needed
+// only for options named like one of the listingParams.
+const optionParamPrefix = "option."
+
+// listingParams are the query params of the listing route that are never
+// option filters.
+var listingParams = map[string]bool{
+	"category":      true,
+	"discounted":    true,
+	"inStock":       true,
+	"minPrice":      true,
+	"maxPrice":      true,
+	"sort":          true,
+	"cursor":        true,
+	"limit":         true,
+	"includeCount":  true,
+	"includeFacets": true,
+}
+
+// hasOptionParams reports whether the request has params that may filter on
+// a variant option, so that the option names only get looked up then.
+func hasOptionParams(r *http.Request) bool {
+	for key := range r.URL.Query() {
+		if !listingParams[key] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseProductFilter reads the listing filters from the query params. Any
+// other param named like a variant option filters on it, e.g.
+// ?color=red&color=blue&size=M, params that are not an option name, like
+// tracking tags, are ignored. optionParamPrefix can be put in front of an
+// option named like a listing param, e.g. ?option.category=shoes.
+func parseProductFilter(r *http.Request, optionNames map[string]bool) (database.ProductFilter, error) {
+	query := r.URL.Query()
+	filter := database.ProductFilter{
+		Category:   query.Get("category"),
+		Discounted: query.Get("discounted") == "true",
+		InStock:    query.Get("inStock") == "true",
+		Options:    map[string][]string{},
+	}
+
+	if minPrice := query.Get("minPrice"); minPrice != "" {
+		value, err := strconv.ParseFloat(minPrice, 64)
+		if err != nil || value < 0 {
+			return filter, errors.New("invalid minPrice")
+		}
+		filter.MinPrice = &value
+	}
+
+	if maxPrice := query.Get("maxPrice"); maxPrice != "" {
+		value, err := strconv.ParseFloat(maxPrice, 64)
+		if err != nil || value < 0 {
+			return filter, errors.New("invalid maxPrice")
+		}
+		filter.MaxPrice = &value
+	}
+
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, errors.New("minPrice cannot be greater than maxPrice")
+	}
+
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, optionParamPrefix)
+		if !ok {
+			if listingParams[key] || !optionNames[strings.ToLower(key)] {
+				continue
+			}
+		} else if name == "" {
+			continue
+		}
+
+		for _, value := range values {
+			if value != "" {
+				filter.Options[name] = append(filter.Options[name], value)
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+// parsePage reads the cursor, limit and includeCount query params shared by
+// the listing and search routes.
+func parsePage(r *http.Request) (string, int, bool, error) {
+	query := r.URL.Query()
+	limit := defaultProductsLimit
+
+	if limitParam := query.Get("limit"); limitParam != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxProductsLimit {
+			return "", 0, false, errors.New("limit must be between 1 and " + strconv.Itoa(maxProductsLimit))
+		}
+	}
+
+	return query.Get("cursor"), limit, query.Get("includeCount") == "true", nil
+}
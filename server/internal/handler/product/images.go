@@ -0,0 +1,149 @@
+package product_handler
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Aaditya-23/server/internal/catalog"
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/images"
+	"github.com/Aaditya-23/server/internal/storage"
+	"github.com/Aaditya-23/server/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const defaultImageMaxBytes = 5 << 20
+
+// imageMaxBytes is the largest image that can be uploaded, set with
+// IMAGE_MAX_BYTES.
+func imageMaxBytes() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+
+	return defaultImageMaxBytes
+}
+
+// uploadImages stores every file of the multipart "images" field along with
+// its thumbnail and returns their keys, which products and variants then refer
+// to with imageKeys.
+func uploadImages(w http.ResponseWriter, r *http.Request) {
+	maxBytes := imageMaxBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes*catalog.MaxProductImages+(1<<20))
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["images"]
+	if len(files) == 0 || len(files) > catalog.MaxProductImages {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "upload between 1 and " + strconv.Itoa(catalog.MaxProductImages) + " images"})
+		return
+	}
+
+	uploaded := []database.Image{}
+	for _, header := range files {
+		if header.Size > maxBytes {
+			utils.ToJSON(w, 413, utils.ErrResponse{Error: header.Filename + " is larger than " + strconv.FormatInt(maxBytes, 10) + " bytes"})
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			println("error occured while opening the uploaded image", err.Error())
+			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			println("error occured while reading the uploaded image", err.Error())
+			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
+		}
+
+		processed, err := images.Process(data)
+		if err != nil {
+			utils.ToJSON(w, 400, utils.ErrResponse{Error: header.Filename + ": " + err.Error()})
+			return
+		}
+
+		id := uuid.NewString()
+		image := database.Image{
+			Key:                  id + processed.Extension,
+			ThumbnailKey:         id + "_thumb" + processed.ThumbnailExtension,
+			ContentType:          processed.ContentType,
+			ThumbnailContentType: processed.ThumbnailContentType,
+			Width:                processed.Width,
+			Height:               processed.Height,
+			Size:                 len(data),
+		}
+
+		if err := storage.Put(r.Context(), image.Key, data, processed.ContentType); err != nil {
+			println("error occured while storing the image", err.Error())
+			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
+		}
+
+		if err := storage.Put(r.Context(), image.ThumbnailKey, processed.Thumbnail, processed.ThumbnailContentType); err != nil {
+			println("error occured while storing the thumbnail", err.Error())
+			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
+		}
+
+		if err := database.CreateImage(image); err != nil {
+			println("error occured while saving the image", err.Error())
+			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
+		}
+
+		uploaded = append(uploaded, image)
+	}
+
+	utils.ToJSON(w, 201, struct {
+		Images []database.Image `json:"images"`
+	}{uploaded})
+}
+
+// fetchImage serves an image or thumbnail by its key. Keys are never reused so
+// the response can be cached for good. Only keys recorded on upload are
+// served, never whatever else the storage holds.
+func fetchImage(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+
+	contentType, err := database.FetchImageContentType(key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "image not found"})
+			return
+		}
+
+		println("error occured while fetching the image", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	image, err := storage.Get(r.Context(), key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "image not found"})
+			return
+		}
+
+		println("error occured while fetching the image", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+	defer image.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, image)
+}
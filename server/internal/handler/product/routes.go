@@ -1,6 +1,7 @@
 package product_handler
 
 import (
+	"github.com/Aaditya-23/server/internal/database"
 	"github.com/Aaditya-23/server/internal/handler/middlewares"
 	"github.com/go-chi/chi/v5"
 )
@@ -11,10 +12,23 @@ func Mount() *chi.Mux {
 
 	r.Route("/", func(r chi.Router) {
 		r.Use(middlewares.AuthMiddleware)
+		r.Use(middlewares.RequireRole(database.RoleAdmin))
 		r.Post("/", createProduct)
+		r.Post("/images", uploadImages)
+		r.Post("/import", importProducts)
 		r.Post("/delete", deleteProduct)
+		r.Patch("/{id}", updateProduct)
+		r.Post("/{id}/categories", setProductCategories)
+		r.Post("/{id}/restore", restoreProduct)
 	})
 
+	// registered here rather than in the admin group above, which a GET
+	// would only reach after /{id}
+	r.With(middlewares.AuthMiddleware, middlewares.RequireRole(database.RoleAdmin)).Get("/export", exportProducts)
+
+	r.Get("/", listProducts)
+	r.Get("/search", searchProducts)
+	r.Get("/images/{key}", fetchImage)
 	r.Get("/{offset}-{limit}", fetchProducts)
 	r.Get("/{id}", fetchProduct)
 
@@ -1,17 +1,18 @@
 package user_handler
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
+	"database/sql"
 	"net/http"
-	"net/url"
-	"os"
+	"strconv"
+	"time"
 
 	"github.com/Aaditya-23/server/internal/auth"
 	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/handler/middlewares"
+	"github.com/Aaditya-23/server/internal/mail"
 	"github.com/Aaditya-23/server/internal/utils"
 	v "github.com/aaditya-23/validator"
+	"github.com/go-chi/chi/v5"
 )
 
 func authWithEmail(w http.ResponseWriter, r *http.Request) {
@@ -48,10 +49,13 @@ func authWithEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	locale := mail.MatchLocale(r.Header.Get("Accept-Language"))
+
 	if user_id == 0 {
 		if err := database.CreateUser(body.Email); err != nil {
 			println("error occured while creating a user", err.Error())
 			utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+			return
 		}
 		user_id, err = database.GetUserId(body.Email)
 		if err != nil {
@@ -61,17 +65,14 @@ func authWithEmail(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tokenId, err := auth.SendMagicLink(user_id, body.Email)
+	login, err := auth.SendMagicLink(r.Context(), user_id, body.Email, locale)
 	if err != nil {
 		println("error occured while sending magic link", err.Error())
 		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
 	}
 
-	utils.ToJSON(w, 202, struct {
-		TokenId int64 `json:"tokenId"`
-	}{
-		tokenId,
-	})
+	utils.ToJSON(w, 202, login)
 }
 
 func verifyMagicToken(w http.ResponseWriter, r *http.Request) {
@@ -95,8 +96,12 @@ func verifyMagicToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isTokenVerified, err := auth.VerifyMagicToken(*body.Token)
-	if err != nil {
+	if err := auth.VerifyMagicToken(*body.Token); err != nil {
+		if status, ok := magicTokenErrors[err]; ok {
+			utils.ToJSON(w, status, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
 		println("error occured while verifying magic token", err.Error())
 		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
@@ -104,12 +109,13 @@ func verifyMagicToken(w http.ResponseWriter, r *http.Request) {
 
 	utils.ToJSON(w, 200, struct {
 		IsTokenVerified bool `json:"isTokenVerified"`
-	}{isTokenVerified})
+	}{true})
 }
 
 func checkRegisteredMagicToken(w http.ResponseWriter, r *http.Request) {
 	type ResBody struct {
-		TokenId *int64 `json:"tokenId"`
+		TokenId *string `json:"tokenId"`
+		Nonce   *string `json:"nonce"`
 	}
 
 	var body ResBody
@@ -119,7 +125,11 @@ func checkRegisteredMagicToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	errs := v.Number(body.TokenId, "tokenId").
+	errs := v.Struct(&body).
+		Fields(
+			v.String(body.TokenId, "tokenId"),
+			v.String(body.Nonce, "nonce"),
+		).
 		Parse()
 
 	if len(errs) > 0 {
@@ -127,199 +137,245 @@ func checkRegisteredMagicToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isTokenRegistered, err := auth.CheckRegisteredMagicToken(*body.TokenId)
+	userId, verified, err := auth.ConsumeMagicToken(auth.PendingLogin{TokenId: *body.TokenId, Nonce: *body.Nonce})
 	if err != nil {
-		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
-		return
-	}
-
-	if !isTokenRegistered {
-		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Token"})
-		return
-	}
+		if status, ok := magicTokenErrors[err]; ok {
+			utils.ToJSON(w, status, utils.ErrResponse{Error: err.Error()})
+			return
+		}
 
-	userId, err := database.GetUserIdFromRegisteredMagicToken(*body.TokenId)
-	if err != nil {
 		println("error occured while checkingRegisteredMagicToken", err.Error())
 		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	sessionId, expires, err := database.CreateUserSession(userId)
+	sessionId, expires, err := auth.StartSession(userId, middlewares.ByIP(r), r.UserAgent())
 	if err != nil {
 		println("error occured while creating user session", err.Error())
 		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
+	// the welcome waits until the address is proven to belong to the user
+	if verified {
+		sendWelcome(r, userId)
+	}
+
 	utils.SetCookie(w, "session", sessionId, expires)
 	utils.ToJSON(w, 200, struct {
 		Message string `json:"message"`
 	}{"Authentication Successful"})
 }
 
-func authWithGithub(w http.ResponseWriter, r *http.Request) {
-	type UserDetail struct {
-		Name  string  `json:"name"`
-		Email *string `json:"email"`
+func fetchProfile(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
+	profile, err := database.FetchProfile(userId)
+	if err != nil {
+		println("error occured while fetching user's profile,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
 	}
 
-	type EmailDetails struct {
-		Email   string `json:"email"`
-		Primary bool   `json:"primary"`
-	}
+	utils.ToJSON(w, 200, profile)
+}
 
-	redirect := func(isSuccess bool) {
-		URL := "https://react-go-rouge.vercel.app/auth?"
-		if isSuccess {
-			URL += "success=auth%20successful"
-		} else {
-			URL += "error=something%20went%20wrong"
-		}
+func logout(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		utils.ToJSON(w, 400, nil)
+		return
+	}
 
-		handler := http.RedirectHandler(URL, http.StatusFound)
-		handler.ServeHTTP(w, r)
+	sessionId := cookie.Value
+	if err := database.DestroySession(sessionId); err != nil {
+		utils.ToJSON(w, 500, nil)
+		return
 	}
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
 
-	code := r.URL.Query().Get("code")
-	clientId := os.Getenv("GITHUB_CLIENT_ID")
-	clientSecret := os.Getenv("GITHUB_CLIENT_SECRET")
+	utils.ToJSON(w, 200, nil)
+}
 
-	TOKEN_URL := fmt.Sprintf("https://github.com/login/oauth/access_token?client_id=%s&client_secret=%s&code=%s", clientId, clientSecret, code)
+func updateUserRole(w http.ResponseWriter, r *http.Request) {
+	type ResBody struct {
+		Role *string `json:"role"`
+	}
 
-	tokenRes, err := http.Post(TOKEN_URL, "application/json", nil)
+	userId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		println("an error occured while exchanging access_token with github,", err.Error())
-		redirect(false)
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "invalid url params"})
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(tokenRes.Body)
-	if err != nil {
-		println("an error occured while exchanging access_token with github,", err.Error())
-		redirect(false)
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
 		return
 	}
 
-	formValues, err := url.ParseQuery(string(bodyBytes))
-	if err != nil {
-		println("an error occured while parsing form values,", err.Error())
-		redirect(false)
+	errs := v.String(body.Role, "role").
+		Refine(utils.OneOf("role", database.Roles)).
+		Parse()
+
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
 		return
 	}
 
-	accessToken := formValues.Get("access_token")
+	if err := database.SetUserRole(userId, *body.Role); err != nil {
+		if err == sql.ErrNoRows {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: "user not found"})
+			return
+		}
 
-	newReq, err := http.NewRequest("GET", "https://api.github.com/user", nil)
-	if err != nil {
-		println("an error occured while creating request for users github profile,", err.Error())
-		redirect(false)
+		println("error occured while updating user role,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	newReq.Header.Add("Authorization", "Bearer "+accessToken)
+	utils.ToJSON(w, 200, struct {
+		Role string `json:"role"`
+	}{*body.Role})
+}
 
-	client := &http.Client{}
-	userDetailRes, err := client.Do(newReq)
+// fetchIdentities lists the ways the user can sign in. Signing in with a
+// link to the account email always works, so it is listed without an id and
+// cannot be unlinked.
+func fetchIdentities(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
+
+	profile, err := database.FetchProfile(userId)
 	if err != nil {
-		println("an error occured while requesting users github profile,", err.Error())
-		redirect(false)
+		println("error occured while fetching user's profile,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	var userDetails UserDetail
-	if err := json.NewDecoder(userDetailRes.Body).Decode(&userDetails); err != nil {
-		redirect(false)
+	identities, err := database.FetchIdentities(userId)
+	if err != nil {
+		println("error occured while fetching user's identities,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	if userDetails.Email == nil {
-		emailsReq, err := http.NewRequest("GET", "https://api.github.com/user/emails", nil)
-		if err != nil {
-			println("an error occured while creating request to fetch users email from github,", err.Error())
-			redirect(false)
-			return
-		}
+	utils.ToJSON(w, 200, struct {
+		Email      string              `json:"email"`
+		Identities []database.Identity `json:"identities"`
+	}{profile.Email, identities})
+}
 
-		emailsReq.Header.Add("Authorization", "Bearer "+accessToken)
-		emailsRes, err := client.Do(emailsReq)
-		if err != nil {
-			println("an error occured while fetching users email from github,", err.Error())
-			redirect(false)
-			return
-		}
+func unlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
 
-		var emails []EmailDetails
-		if err := json.NewDecoder(emailsRes.Body).Decode(&emails); err != nil {
-			redirect(false)
-			return
-		}
+	type ResBody struct {
+		IdentityId *int64 `json:"identityId"`
+	}
 
-		for _, value := range emails {
-			if value.Primary {
-				userDetails.Email = &value.Email
-				break
-			}
-		}
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
+		return
 	}
 
-	userId, err := database.GetUserId(*userDetails.Email)
-	if err != nil {
-		println("error occured while checking user in the database", err.Error())
-		redirect(false)
+	errs := v.Number(body.IdentityId, "identityId").Parse()
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
 		return
-	} else if userId == 0 {
-		if err := database.CreateUser(*userDetails.Email); err != nil {
-			println("an error occured while creating user in the database,", err.Error())
-			redirect(false)
-			return
-		}
+	}
 
-		userId, err = database.GetUserId(*userDetails.Email)
-		if err != nil {
-			println("error occured while checking user in the database", err.Error())
-			redirect(false)
+	if err := database.UnlinkIdentity(userId, *body.IdentityId); err != nil {
+		if err == database.ErrIdentityNotFound {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: err.Error()})
 			return
 		}
-	}
 
-	sessionId, expires, err := database.CreateUserSession(userId)
-	if err != nil {
-		println("error occured while creating user session", err.Error())
-		redirect(false)
+		println("error occured while unlinking identity,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	utils.SetCookie(w, "session", sessionId, expires)
-	redirect(true)
+	utils.ToJSON(w, 200, nil)
 }
 
-func fetchProfile(w http.ResponseWriter, r *http.Request) {
+// fetchSessions lists the devices the user is signed in on, the one making the
+// request is marked as current.
+func fetchSessions(w http.ResponseWriter, r *http.Request) {
 	userId := r.Context().Value("userId").(int64)
-	profile, err := database.FetchProfile(userId)
+	currentId := r.Context().Value("sessionId").(string)
+
+	sessions, err := database.FetchUserSessions(userId)
 	if err != nil {
-		println("error occured while fetching user's profile,", err.Error())
+		println("error occured while fetching user's sessions,", err.Error())
 		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	utils.ToJSON(w, 200, profile)
+	type SessionDetail struct {
+		database.Session
+		Current bool `json:"current"`
+	}
+
+	details := make([]SessionDetail, len(sessions))
+	for i, session := range sessions {
+		details[i] = SessionDetail{session, session.PublicId == currentId}
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Sessions []SessionDetail `json:"sessions"`
+	}{details})
 }
 
-func logout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		utils.ToJSON(w, 400, nil)
+func revokeSession(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
+	currentId := r.Context().Value("sessionId").(string)
+
+	type ResBody struct {
+		SessionId *string `json:"sessionId"`
+	}
+
+	var body ResBody
+	if err := utils.DecodeJSON(r, &body); err != nil {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: "Invalid Request Body"})
 		return
 	}
 
-	sessionId := cookie.Value
-	if err := database.DestroySession(sessionId); err != nil {
-		utils.ToJSON(w, 500, nil)
+	errs := v.String(body.SessionId, "sessionId").Parse()
+	if len(errs) > 0 {
+		utils.ToJSON(w, 400, utils.ErrResponse{Error: errs[0].Message})
 		return
 	}
-	cookie.MaxAge = -1
-	http.SetCookie(w, cookie)
+
+	if err := database.RevokeSession(userId, *body.SessionId); err != nil {
+		if err == database.ErrSessionNotFound {
+			utils.ToJSON(w, 404, utils.ErrResponse{Error: err.Error()})
+			return
+		}
+
+		println("error occured while revoking session,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	if *body.SessionId == currentId {
+		utils.SetCookie(w, "session", "", time.Unix(0, 0))
+	}
 
 	utils.ToJSON(w, 200, nil)
 }
+
+func revokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userId := r.Context().Value("userId").(int64)
+	currentId := r.Context().Value("sessionId").(string)
+
+	revoked, err := database.RevokeOtherSessions(userId, currentId)
+	if err != nil {
+		println("error occured while revoking other sessions,", err.Error())
+		utils.ToJSON(w, 500, utils.ErrResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	utils.ToJSON(w, 200, struct {
+		Revoked int64 `json:"revoked"`
+	}{revoked})
+}
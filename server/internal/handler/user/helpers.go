@@ -1 +1,32 @@
 package user_handler
+
+import (
+	"net/http"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/mail"
+	"github.com/Aaditya-23/server/internal/notify"
+)
+
+// magicTokenErrors are the statuses of the magic token errors. A pending token
+// is a client error as well, the client polls until the link is clicked.
+var magicTokenErrors = map[error]int{
+	database.ErrMagicTokenInvalid: 400,
+	database.ErrMagicTokenPending: 400,
+	database.ErrMagicTokenUsed:    409,
+	database.ErrMagicTokenExpired: 410,
+}
+
+// sendWelcome welcomes a user once their email is verified. Failing to send it
+// does not fail the sign in.
+func sendWelcome(r *http.Request, userId int64) {
+	profile, err := database.FetchProfile(userId)
+	if err == nil {
+		locale := mail.MatchLocale(r.Header.Get("Accept-Language"))
+		err = notify.Welcome(r.Context(), profile.Email, locale)
+	}
+
+	if err != nil {
+		println("error occured while sending welcome email", err.Error())
+	}
+}
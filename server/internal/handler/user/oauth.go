@@ -0,0 +1,275 @@
+package user_handler
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Aaditya-23/server/internal/auth"
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/handler/middlewares"
+	"github.com/Aaditya-23/server/internal/oauth"
+	"github.com/Aaditya-23/server/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	oauthCookie = "oauth_flow"
+	// oauthFlowTTL is how long the user has to sign in at the provider.
+	oauthFlowTTL = 10 * time.Minute
+)
+
+// A flow either signs the user in or links the provider account to the user
+// that is already signed in.
+const (
+	oauthLogin = "login"
+	oauthLink  = "link"
+)
+
+func startOAuth(w http.ResponseWriter, r *http.Request) {
+	beginOAuth(w, r, oauthLogin)
+}
+
+// linkIdentity starts a flow that adds the provider account as another way to
+// sign in to the current user.
+func linkIdentity(w http.ResponseWriter, r *http.Request) {
+	beginOAuth(w, r, oauthLink)
+}
+
+// beginOAuth sends the browser to the provider. The state and the PKCE
+// verifier stay behind in a cookie for the callback to check.
+func beginOAuth(w http.ResponseWriter, r *http.Request, mode string) {
+	provider, err := oauth.Lookup(chi.URLParam(r, "provider"))
+	if err != nil {
+		redirectAfterOAuth(w, r, mode, err.Error())
+		return
+	}
+
+	flow, err := oauth.NewFlow()
+	if err != nil {
+		println("an error occured while starting oauth flow,", err.Error())
+		redirectAfterOAuth(w, r, mode, "something went wrong")
+		return
+	}
+
+	authURL, err := provider.AuthURL(r.Context(), flow.State, flow.Challenge())
+	if err != nil {
+		println("an error occured while building "+provider.Name()+" auth url,", err.Error())
+		redirectAfterOAuth(w, r, mode, "something went wrong")
+		return
+	}
+
+	value := strings.Join([]string{provider.Name(), mode, flow.State, flow.Verifier}, ".")
+	utils.SetCookie(w, oauthCookie, value, time.Now().Add(oauthFlowTTL))
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func oauthCallback(w http.ResponseWriter, r *http.Request) {
+	completeOAuth(w, r, chi.URLParam(r, "provider"))
+}
+
+// authWithGithub is the callback url the GitHub app was registered with before
+// the other providers existed.
+func authWithGithub(w http.ResponseWriter, r *http.Request) {
+	completeOAuth(w, r, "github")
+}
+
+func completeOAuth(w http.ResponseWriter, r *http.Request, providerName string) {
+	provider, err := oauth.Lookup(providerName)
+	if err != nil {
+		redirectToClient(w, r, err.Error())
+		return
+	}
+
+	mode, flow, ok := readOAuthFlow(r, provider.Name())
+	// the flow is single use whatever happens next
+	utils.SetCookie(w, oauthCookie, "", time.Unix(0, 0))
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		redirectAfterOAuth(w, r, mode, "sign in was cancelled")
+		return
+	}
+
+	if !ok || !flow.MatchesState(query.Get("state")) {
+		redirectAfterOAuth(w, r, mode, "sign in expired, please try again")
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), query.Get("code"), flow.Verifier)
+	if err != nil {
+		println("an error occured while exchanging code with "+provider.Name()+",", err.Error())
+		redirectAfterOAuth(w, r, mode, "something went wrong")
+		return
+	}
+
+	user, err := provider.User(r.Context(), token)
+	if err != nil {
+		println("an error occured while fetching user from "+provider.Name()+",", err.Error())
+		redirectAfterOAuth(w, r, mode, "something went wrong")
+		return
+	}
+
+	if mode == oauthLink {
+		completeLink(w, r, provider.Name(), user)
+		return
+	}
+
+	userId, err := userIdForIdentity(r, provider.Name(), user)
+	if err != nil {
+		if err == oauth.ErrNoVerifiedEmail {
+			redirectToClient(w, r, err.Error())
+			return
+		}
+
+		println("error occured while finding user for "+provider.Name()+" identity,", err.Error())
+		redirectToClient(w, r, "something went wrong")
+		return
+	}
+
+	sessionId, expires, err := auth.StartSession(userId, middlewares.ByIP(r), r.UserAgent())
+	if err != nil {
+		println("error occured while creating user session", err.Error())
+		redirectToClient(w, r, "something went wrong")
+		return
+	}
+
+	utils.SetCookie(w, "session", sessionId, expires)
+	redirectToClient(w, r, "")
+}
+
+// userIdForIdentity finds the user by the provider account first. The email is
+// only trusted for an account seen for the first time and only when the
+// provider verified it, the account is then linked to the user with that email
+// or to a new one.
+func userIdForIdentity(r *http.Request, provider string, user oauth.User) (int64, error) {
+	userId, err := database.GetUserIdByIdentity(provider, user.Subject)
+	if err != nil {
+		return 0, err
+	}
+	if userId != 0 {
+		return userId, database.TouchIdentity(provider, user.Subject, user.Email)
+	}
+
+	if user.Email == "" || !user.EmailVerified {
+		return 0, oauth.ErrNoVerifiedEmail
+	}
+
+	userId, err = database.GetUserId(user.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	if userId == 0 {
+		if err := database.CreateUser(user.Email); err != nil {
+			return 0, err
+		}
+
+		userId, err = database.GetUserId(user.Email)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// the provider verified the email, which may be the first time for a user
+	// who signed up by email but never used the magic link
+	verified, err := database.MarkEmailVerified(userId)
+	if err != nil {
+		return 0, err
+	}
+	if verified {
+		sendWelcome(r, userId)
+	}
+
+	return userId, database.LinkIdentity(userId, provider, user.Subject, user.Email)
+}
+
+// completeLink links the provider account to whoever is signed in now, the
+// session is read again rather than trusted from when the flow started.
+func completeLink(w http.ResponseWriter, r *http.Request, provider string, user oauth.User) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		redirectToProfile(w, r, "sign in to link an account")
+		return
+	}
+
+	// resumed like any other request, so a cookie that was just rotated away
+	// still works within the grace period and a renewed one is sent back
+	session, renewed, err := auth.ResumeSession(cookie.Value, middlewares.ByIP(r), r.UserAgent())
+	if err != nil {
+		if err != sql.ErrNoRows {
+			println("error occured while reading session,", err.Error())
+		}
+		redirectToProfile(w, r, "sign in to link an account")
+		return
+	}
+
+	if renewed != nil {
+		utils.SetCookie(w, "session", renewed.Id, renewed.Expires)
+	}
+
+	if err := database.LinkIdentity(session.UserId, provider, user.Subject, user.Email); err != nil {
+		if err == database.ErrIdentityTaken {
+			redirectToProfile(w, r, err.Error())
+			return
+		}
+
+		println("error occured while linking "+provider+" identity,", err.Error())
+		redirectToProfile(w, r, "something went wrong")
+		return
+	}
+
+	redirectToProfile(w, r, "")
+}
+
+// readOAuthFlow returns the flow started for the provider in this browser.
+func readOAuthFlow(r *http.Request, providerName string) (string, oauth.Flow, bool) {
+	cookie, err := r.Cookie(oauthCookie)
+	if err != nil {
+		return oauthLogin, oauth.Flow{}, false
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 || parts[0] != providerName || (parts[1] != oauthLogin && parts[1] != oauthLink) {
+		return oauthLogin, oauth.Flow{}, false
+	}
+
+	return parts[1], oauth.Flow{State: parts[2], Verifier: parts[3]}, true
+}
+
+func redirectAfterOAuth(w http.ResponseWriter, r *http.Request, mode, errMessage string) {
+	if mode == oauthLink {
+		redirectToProfile(w, r, errMessage)
+	} else {
+		redirectToClient(w, r, errMessage)
+	}
+}
+
+// redirectToProfile sends the browser back to the profile page of the client
+// after linking an account.
+func redirectToProfile(w http.ResponseWriter, r *http.Request, errMessage string) {
+	query := url.Values{}
+	if errMessage == "" {
+		query.Set("success", "account linked")
+	} else {
+		query.Set("error", errMessage)
+	}
+
+	http.Redirect(w, r, utils.FrontendURL("/profile")+"?"+query.Encode(), http.StatusFound)
+}
+
+// redirectToClient sends the browser back to the auth page of the client,
+// which shows errMessage or that the sign in worked when it is empty.
+func redirectToClient(w http.ResponseWriter, r *http.Request, errMessage string) {
+	query := url.Values{}
+	if errMessage == "" {
+		query.Set("success", "auth successful")
+	} else {
+		query.Set("error", errMessage)
+	}
+
+	http.Redirect(w, r, utils.FrontendURL("/auth")+"?"+query.Encode(), http.StatusFound)
+}
@@ -1,7 +1,11 @@
 package user_handler
 
 import (
+	"time"
+
+	"github.com/Aaditya-23/server/internal/database"
 	"github.com/Aaditya-23/server/internal/handler/middlewares"
+	"github.com/Aaditya-23/server/internal/ratelimit"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -13,12 +17,39 @@ func Mount() *chi.Mux {
 		r.Use(middlewares.AuthMiddleware)
 
 		r.Get("/profile", fetchProfile)
+		r.Get("/sessions", fetchSessions)
+		r.Post("/sessions/revoke", revokeSession)
+		r.Post("/sessions/revoke-others", revokeOtherSessions)
+		r.Get("/identities", fetchIdentities)
+		r.Post("/identities/unlink", unlinkIdentity)
+		r.With(middlewares.RateLimit(
+			middlewares.RateLimitRule{Name: "oauth_ip", Limit: ratelimit.Limit{Requests: 20, Per: 15 * time.Minute}, Key: middlewares.ByIP},
+		)).Get("/identities/{provider}/link", linkIdentity)
+		r.With(middlewares.RequireRole(database.RoleAdmin)).Post("/{id}/role", updateUserRole)
 	})
 
-	r.Post("/auth-with-email", authWithEmail)
-	r.Get("/auth-with-github", authWithGithub)
-	r.Post("/verify-magic-token", verifyMagicToken)
-	r.Post("/check-registered-magic-token", checkRegisteredMagicToken)
+	r.With(middlewares.RateLimit(
+		middlewares.RateLimitRule{Name: "auth_email_ip", Limit: ratelimit.Limit{Requests: 10, Per: 15 * time.Minute}, Key: middlewares.ByIP},
+		middlewares.RateLimitRule{Name: "auth_email", Limit: ratelimit.Limit{Requests: 3, Per: 15 * time.Minute}, Key: middlewares.ByJSONField("email")},
+	)).Post("/auth-with-email", authWithEmail)
+	r.Route("/oauth/{provider}", func(r chi.Router) {
+		r.Use(middlewares.RateLimit(
+			middlewares.RateLimitRule{Name: "oauth_ip", Limit: ratelimit.Limit{Requests: 20, Per: 15 * time.Minute}, Key: middlewares.ByIP},
+		))
+		r.Get("/", startOAuth)
+		r.Get("/callback", oauthCallback)
+	})
+	r.With(middlewares.RateLimit(
+		middlewares.RateLimitRule{Name: "auth_github_ip", Limit: ratelimit.Limit{Requests: 20, Per: 15 * time.Minute}, Key: middlewares.ByIP},
+	)).Get("/auth-with-github", authWithGithub)
+	r.With(middlewares.RateLimit(
+		middlewares.RateLimitRule{Name: "verify_magic_token_ip", Limit: ratelimit.Limit{Requests: 20, Per: 15 * time.Minute}, Key: middlewares.ByIP},
+	)).Post("/verify-magic-token", verifyMagicToken)
+	// the client polls this one every couple of seconds while the email is
+	// on its way
+	r.With(middlewares.RateLimit(
+		middlewares.RateLimitRule{Name: "check_magic_token_ip", Limit: ratelimit.Limit{Requests: 300, Per: 15 * time.Minute}, Key: middlewares.ByIP},
+	)).Post("/check-registered-magic-token", checkRegisteredMagicToken)
 	r.Post("/logout", logout)
 
 	return r
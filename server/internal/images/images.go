@@ -0,0 +1,168 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	thumbnailSize = 320
+	// maxPixels caps the decoded size of an image. A small file can declare
+	// huge dimensions and decoding it would allocate width*height pixels, at
+	// 4 bytes each this is at most 80 MB.
+	maxPixels = 20_000_000
+	// maxDecodes bounds how many images are decoded at once, so that
+	// concurrent uploads cannot add up to more memory than that.
+	maxDecodes = 2
+)
+
+var decodeSlots = make(chan struct{}, maxDecodes)
+
+var (
+	ErrUnsupportedType = errors.New("only jpeg, png and gif images are supported")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type Processed struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	// Thumbnail fits in a thumbnailSize square and is always a jpeg, or a png
+	// for images that may be transparent.
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailExtension   string
+}
+
+// Process sniffs the content type of data, rather than trusting the one sent
+// by the client, rejects images with more than maxPixels pixels, makes sure it
+// decodes as an image and renders its thumbnail.
+func Process(data []byte) (Processed, error) {
+	var processed Processed
+
+	contentType := http.DetectContentType(data)
+	extension, ok := extensions[contentType]
+	if !ok {
+		return processed, ErrUnsupportedType
+	}
+
+	var (
+		config image.Config
+		err    error
+	)
+
+	switch contentType {
+	case "image/jpeg":
+		config, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	case "image/png":
+		config, err = png.DecodeConfig(bytes.NewReader(data))
+	case "image/gif":
+		config, err = gif.DecodeConfig(bytes.NewReader(data))
+	}
+	if err != nil {
+		return processed, errors.New("image could not be decoded")
+	}
+
+	// checked before decoding so that the pixels are never allocated
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxPixels {
+		return processed, ErrTooManyPixels
+	}
+
+	decodeSlots <- struct{}{}
+	defer func() { <-decodeSlots }()
+
+	var img image.Image
+
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return processed, errors.New("image could not be decoded")
+	}
+
+	processed.ContentType = contentType
+	processed.Extension = extension
+	processed.Width = img.Bounds().Dx()
+	processed.Height = img.Bounds().Dy()
+
+	thumbnail := resize(img, thumbnailSize)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85})
+		processed.ThumbnailContentType, processed.ThumbnailExtension = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(&buf, thumbnail)
+		processed.ThumbnailContentType, processed.ThumbnailExtension = "image/png", ".png"
+	}
+	if err != nil {
+		return processed, err
+	}
+	processed.Thumbnail = buf.Bytes()
+
+	return processed, nil
+}
+
+// resize scales img down to fit in a size by size square, averaging the
+// source pixels that fall into every pixel of the result. Images that already
+// fit are only copied.
+func resize(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	width, height := srcWidth, srcHeight
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, srcHeight*size/srcWidth)
+		} else {
+			width, height = max(1, srcWidth*size/srcHeight), size
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcHeight/height)
+
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcWidth/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"context"
+	"time"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/storage"
+)
+
+const (
+	sweepInterval = time.Hour
+	// unattachedRetention gives an upload a day to be added to a product
+	// before it is deleted.
+	unattachedRetention = 24 * time.Hour
+	sweepBatchSize      = 100
+)
+
+// StartSweeper deletes the uploads that were never added to a product every
+// hour in the background.
+func StartSweeper() {
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			sweep()
+			<-ticker.C
+		}
+	}()
+}
+
+func sweep() {
+	unattached, err := database.FetchUnattachedImages(unattachedRetention, sweepBatchSize)
+	if err != nil {
+		println("error occured while fetching unattached images", err.Error())
+		return
+	}
+
+	deleted := 0
+	for _, image := range unattached {
+		removed, err := database.DeleteUnattachedImage(image.Key)
+		if err != nil {
+			println("error occured while deleting unattached image", err.Error())
+			continue
+		}
+		// a product picked it up in the meantime
+		if !removed {
+			continue
+		}
+
+		// the row is gone so the files are no longer served, a file that
+		// fails to delete is only left behind in the storage
+		for _, key := range []string{image.Key, image.ThumbnailKey} {
+			if err := storage.Delete(context.Background(), key); err != nil && err != storage.ErrNotFound {
+				println("error occured while deleting unattached image file", err.Error())
+			}
+		}
+
+		deleted++
+	}
+
+	if deleted > 0 {
+		println("purged", deleted, "unattached images")
+	}
+}
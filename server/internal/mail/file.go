@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// File writes every message as an .eml file, which mail clients can open, so
+// that the auth flow can be followed without an SMTP server.
+type File struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir, from string) (*File, error) {
+	if dir == "" {
+		dir = "mails"
+	}
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &File{dir: dir, from: from}, nil
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = f.from
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".eml"
+	return os.WriteFile(filepath.Join(f.dir, name), data, 0o644)
+}
@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	// HTML is optional, when it is set the message is sent as
+	// multipart/alternative with Text as the fallback.
+	HTML string
+	// ValidFor is how long the message is worth delivering, e.g. the life of
+	// the link in it, zero when it does not expire. Only mailers that queue
+	// messages look at it.
+	ValidFor time.Duration
+}
+
+// Mailer sends messages. SMTP delivers them, File and Memory keep them for
+// development and tests.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv builds the mailer picked with MAIL_DRIVER, smtp when it is not set.
+func FromEnv() (Mailer, error) {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "", "smtp":
+		return NewSMTP(SMTPConfigFromEnv())
+	case "file":
+		return NewFile(os.Getenv("MAIL_DIR"), os.Getenv("MAIL_FROM"))
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, errors.New("unknown MAIL_DRIVER " + driver)
+	}
+}
+
+// Bytes renders the message as RFC 5322 text.
+func (m Message) Bytes() ([]byte, error) {
+	if m.From == "" || len(m.To) == 0 {
+		return nil, errors.New("message needs a sender and a recipient")
+	}
+
+	messageId, err := messageId(m.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", m.From)
+	writeHeader(&buf, "To", strings.Join(m.To, ", "))
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageId)
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		writeHeader(&buf, "Content-Type", `text/plain; charset="utf-8"`)
+		writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		err = writeQuotedPrintable(&buf, m.Text)
+		return buf.Bytes(), err
+	}
+
+	parts := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", `multipart/alternative; boundary="`+parts.Boundary()+`"`)
+	buf.WriteString("\r\n")
+
+	// the last part is the preferred one
+	if err := writePart(parts, "text/plain", m.Text); err != nil {
+		return nil, err
+	}
+	if err := writePart(parts, "text/html", m.HTML); err != nil {
+		return nil, err
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writePart(parts *multipart.Writer, contentType, content string) error {
+	part, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + `; charset="utf-8"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeQuotedPrintable(part, content)
+}
+
+// writeQuotedPrintable keeps lines short and 7 bit, it also writes every line
+// ending as CRLF.
+func writeQuotedPrintable(w io.Writer, content string) error {
+	writer := quotedprintable.NewWriter(w)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	// a header value must never start a new header
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	buf.WriteString(name + ": " + value + "\r\n")
+}
+
+func messageId(from string) (string, error) {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if _, host, ok := strings.Cut(from, "@"); ok {
+		domain = strings.TrimRight(host, ">")
+	}
+
+	return "<" + hex.EncodeToString(data) + "@" + domain + ">", nil
+}
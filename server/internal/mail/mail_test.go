@@ -0,0 +1,160 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"testing"
+)
+
+func TestMessageBytes(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         Message
+		subject     string
+		to          string
+		contentType string
+		parts       []string
+	}{
+		{
+			name:        "plain text",
+			msg:         Message{From: "shop@example.com", To: []string{"a@example.com"}, Subject: "Hello", Text: "line one\nline two\n"},
+			subject:     "Hello",
+			to:          "a@example.com",
+			contentType: "text/plain",
+			parts:       []string{"line one\r\nline two\r\n"},
+		},
+		{
+			name:        "html with text fallback",
+			msg:         Message{From: "shop@example.com", To: []string{"a@example.com", "b@example.com"}, Subject: "Hello", Text: "hi\n", HTML: "<p>hi</p>"},
+			subject:     "Hello",
+			to:          "a@example.com, b@example.com",
+			contentType: "multipart/alternative",
+			parts:       []string{"hi\r\n", "<p>hi</p>"},
+		},
+		{
+			name:        "non ascii subject",
+			msg:         Message{From: "shop@example.com", To: []string{"a@example.com"}, Subject: "Tu enlace para iniciar sesión", Text: "hola\n"},
+			subject:     "Tu enlace para iniciar sesión",
+			to:          "a@example.com",
+			contentType: "text/plain",
+			parts:       []string{"hola\r\n"},
+		},
+		{
+			name:        "header injection in subject",
+			msg:         Message{From: "shop@example.com", To: []string{"a@example.com"}, Subject: "Hello\r\nBcc: evil@example.com", Text: "hi\n"},
+			subject:     "Hello\r\nBcc: evil@example.com",
+			to:          "a@example.com",
+			contentType: "text/plain",
+			parts:       []string{"hi\r\n"},
+		},
+		{
+			name:        "header injection in recipient",
+			msg:         Message{From: "shop@example.com", To: []string{"a@example.com\r\nBcc: evil@example.com"}, Subject: "Hello", Text: "hi\n"},
+			subject:     "Hello",
+			to:          "a@example.comBcc: evil@example.com",
+			contentType: "text/plain",
+			parts:       []string{"hi\r\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.msg.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+
+			parsed, err := netmail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+
+			if got := parsed.Header.Get("Bcc"); got != "" {
+				t.Errorf("Bcc = %q, want no Bcc header", got)
+			}
+			if got := parsed.Header.Get("To"); got != tt.to {
+				t.Errorf("To = %q, want %q", got, tt.to)
+			}
+
+			subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+			if err != nil || subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", subject, tt.subject)
+			}
+
+			for _, name := range []string{"Date", "Message-ID", "MIME-Version"} {
+				if parsed.Header.Get(name) == "" {
+					t.Errorf("%s header is missing", name)
+				}
+			}
+
+			mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+			if err != nil || mediaType != tt.contentType {
+				t.Fatalf("Content-Type = %q, want %q", mediaType, tt.contentType)
+			}
+
+			var parts []string
+			if mediaType == "multipart/alternative" {
+				if params["boundary"] == "" {
+					t.Fatal("multipart message has no boundary")
+				}
+
+				reader := multipart.NewReader(parsed.Body, params["boundary"])
+				for {
+					part, err := reader.NextRawPart()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatalf("NextRawPart() error = %v", err)
+					}
+
+					parts = append(parts, readQuotedPrintable(t, part))
+				}
+			} else {
+				parts = append(parts, readQuotedPrintable(t, parsed.Body))
+			}
+
+			if len(parts) != len(tt.parts) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.parts))
+			}
+			for i := range parts {
+				if parts[i] != tt.parts[i] {
+					t.Errorf("part %d = %q, want %q", i, parts[i], tt.parts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMessageBytesNeedsAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"no sender", Message{To: []string{"a@example.com"}, Text: "hi"}},
+		{"no recipient", Message{From: "shop@example.com", Text: "hi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.msg.Bytes(); err == nil {
+				t.Error("Bytes() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func readQuotedPrintable(t *testing.T, r io.Reader) string {
+	t.Helper()
+
+	data, err := io.ReadAll(quotedprintable.NewReader(r))
+	if err != nil {
+		t.Fatalf("reading quoted-printable body: %v", err)
+	}
+
+	return string(data)
+}
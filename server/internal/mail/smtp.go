@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	// TLSNone is only meant for local catch-all servers such as MailHog.
+	TLSNone = "none"
+)
+
+const smtpTimeout = 30 * time.Second
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// TLS is one of TLSStartTLS, TLSImplicit or TLSNone.
+	TLS  string
+	From string
+}
+
+// SMTPConfigFromEnv reads the SMTP_* settings. EMAIL and EMAIL_PASSWORD are
+// still read for deployments that predate them.
+func SMTPConfigFromEnv() SMTPConfig {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		TLS:      os.Getenv("SMTP_TLS"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+
+	if config.Host == "" {
+		config.Host = "smtp.gmail.com"
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	if config.Username == "" {
+		config.Username = os.Getenv("EMAIL")
+	}
+	if config.Password == "" {
+		config.Password = os.Getenv("EMAIL_PASSWORD")
+	}
+	if config.TLS == "" {
+		config.TLS = TLSStartTLS
+	}
+	if config.From == "" {
+		config.From = config.Username
+	}
+
+	return config
+}
+
+type SMTP struct {
+	config SMTPConfig
+}
+
+func NewSMTP(config SMTPConfig) (*SMTP, error) {
+	if config.Host == "" || config.Port == "" {
+		return nil, errors.New("smtp host and port are required")
+	}
+	if config.From == "" {
+		return nil, errors.New("MAIL_FROM or SMTP_USERNAME is required")
+	}
+	if config.TLS != TLSStartTLS && config.TLS != TLSImplicit && config.TLS != TLSNone {
+		return nil, errors.New("SMTP_TLS must be starttls, tls or none")
+	}
+
+	return &SMTP{config: config}, nil
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = s.config.From
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	// the envelope only takes the bare address, not "Name <address>"
+	from, err := netmail.ParseAddress(s.config.From)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (s *SMTP) dial(ctx context.Context) (*smtp.Client, error) {
+	address := net.JoinHostPort(s.config.Host, s.config.Port)
+	tlsConfig := &tls.Config{ServerName: s.config.Host}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if s.config.TLS == TLSImplicit {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the whole exchange has to fit in the context deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if s.config.TLS == TLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
@@ -0,0 +1,183 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	TemplateMagicLink         = "magic-link"
+	TemplateWelcome           = "welcome"
+	TemplateOrderConfirmation = "order-confirmation"
+)
+
+// DefaultLocale has every template, the other locales fall back to it for the
+// ones they lack.
+const DefaultLocale = "en"
+
+var templateNames = []string{TemplateMagicLink, TemplateWelcome, TemplateOrderConfirmation}
+
+type MagicLinkData struct {
+	Link             string
+	ExpiresInMinutes int
+}
+
+type WelcomeData struct {
+	ShopURL string
+}
+
+type OrderLine struct {
+	Name string
+	// Variant describes the chosen options, e.g. "color: red, size: M".
+	Variant  string
+	Quantity int
+	Price    float64
+}
+
+type OrderConfirmationData struct {
+	OrderId  int64
+	Items    []OrderLine
+	Subtotal float64
+	Discount float64
+	Total    float64
+	OrderURL string
+}
+
+// Every template is a pair of files under templates/<locale>/. The .txt file
+// is the plain text body and also defines the "subject" template, the .html
+// file defines the "content" that templates/layout.html wraps.
+//
+//go:embed templates
+var templateFS embed.FS
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates is keyed by locale and then by template name.
+var templates = parseTemplates()
+
+func parseTemplates() map[string]map[string]emailTemplate {
+	entries, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		panic(err)
+	}
+
+	parsed := map[string]map[string]emailTemplate{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		locale := entry.Name()
+		parsed[locale] = map[string]emailTemplate{}
+
+		for _, name := range templateNames {
+			textFile := "templates/" + locale + "/" + name + ".txt"
+			if _, err := fs.Stat(templateFS, textFile); err != nil {
+				continue
+			}
+
+			funcs := templateFuncs(locale)
+			text := texttemplate.Must(texttemplate.New(name).Funcs(funcs).ParseFS(templateFS, textFile))
+			html := htmltemplate.Must(htmltemplate.New(name).Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/"+locale+"/"+name+".html"))
+
+			parsed[locale][name] = emailTemplate{text: text, html: html}
+		}
+	}
+
+	for _, name := range templateNames {
+		if _, ok := parsed[DefaultLocale][name]; !ok {
+			panic("mail: template " + name + " is missing for the default locale")
+		}
+	}
+
+	return parsed
+}
+
+func templateFuncs(locale string) map[string]any {
+	return map[string]any{
+		"locale": func() string { return locale },
+		"price": func(value float64) string {
+			return "$" + strconv.FormatFloat(value, 'f', 2, 64)
+		},
+	}
+}
+
+// Render builds the subject and both bodies of a template for the locale, the
+// caller only has to address the message.
+func Render(name, locale string, data any) (Message, error) {
+	var msg Message
+
+	template, ok := templates[locale][name]
+	if !ok {
+		template, ok = templates[DefaultLocale][name]
+	}
+	if !ok {
+		return msg, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := template.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return msg, err
+	}
+	if err := template.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return msg, err
+	}
+	if err := template.html.ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return msg, err
+	}
+
+	msg.Subject = strings.TrimSpace(subject.String())
+	msg.Text = strings.TrimSpace(text.String()) + "\n"
+	msg.HTML = html.String()
+
+	return msg, nil
+}
+
+// MatchLocale picks the supported locale the Accept-Language header prefers
+// most, DefaultLocale when there is none.
+func MatchLocale(acceptLanguage string) string {
+	type preference struct {
+		locale string
+		weight float64
+	}
+
+	var preferences []preference
+	for _, value := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		// only the language is matched, "es-MX" gets the "es" templates
+		language, _, _ := strings.Cut(tag, "-")
+		preferences = append(preferences, preference{strings.ToLower(language), weight})
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].weight > preferences[j].weight
+	})
+
+	for _, preference := range preferences {
+		if _, ok := templates[preference.locale]; ok && preference.weight > 0 {
+			return preference.locale
+		}
+	}
+
+	return DefaultLocale
+}
@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		locale   string
+		data     any
+		subject  string
+		text     []string
+		html     []string
+	}{
+		{
+			name:     "magic link",
+			template: TemplateMagicLink,
+			locale:   "en",
+			data:     MagicLinkData{Link: "https://shop.example.com/verify?token=abc&x=1", ExpiresInMinutes: 15},
+			subject:  "Your sign in link",
+			text:     []string{"https://shop.example.com/verify?token=abc&x=1", "expires in 15 minutes"},
+			html:     []string{`lang="en"`, "https://shop.example.com/verify?token=abc&amp;x=1"},
+		},
+		{
+			name:     "spanish magic link",
+			template: TemplateMagicLink,
+			locale:   "es",
+			data:     MagicLinkData{Link: "https://shop.example.com/verify?token=abc", ExpiresInMinutes: 15},
+			subject:  "Tu enlace para iniciar sesión",
+			text:     []string{"caduca en 15 minutos"},
+			html:     []string{`lang="es"`},
+		},
+		{
+			name:     "unknown locale falls back to the default",
+			template: TemplateWelcome,
+			locale:   "de",
+			data:     WelcomeData{ShopURL: "https://shop.example.com/products"},
+			subject:  "Welcome to the shop",
+			text:     []string{"https://shop.example.com/products"},
+			html:     []string{`lang="en"`},
+		},
+		{
+			name:     "order confirmation",
+			template: TemplateOrderConfirmation,
+			locale:   "en",
+			data: OrderConfirmationData{
+				OrderId:  42,
+				Items:    []OrderLine{{Name: "Shirt", Variant: "color: red", Quantity: 2, Price: 9.5}},
+				Subtotal: 19,
+				Total:    19,
+			},
+			subject: "Order #42 confirmed",
+			text:    []string{"- Shirt (color: red) x 2, $9.50 each", "Total: $19.00"},
+			html:    []string{"Shirt"},
+		},
+		{
+			name:     "links are escaped",
+			template: TemplateWelcome,
+			locale:   "en",
+			data:     WelcomeData{ShopURL: `https://shop.example.com/"><script>`},
+			subject:  "Welcome to the shop",
+			html:     []string{`href="https://shop.example.com/%22%3e%3cscript%3e"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Render(tt.template, tt.locale, tt.data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			if msg.Subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			for _, want := range tt.text {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("Text = %q, want it to contain %q", msg.Text, want)
+				}
+			}
+			for _, want := range tt.html {
+				if !strings.Contains(msg.HTML, want) {
+					t.Errorf("HTML = %q, want it to contain %q", msg.HTML, want)
+				}
+			}
+			if strings.Contains(msg.HTML, "<script>") {
+				t.Error("HTML contains an unescaped <script>")
+			}
+		})
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	if _, err := Render("missing", "en", nil); err == nil {
+		t.Error("Render() error = nil, want an error")
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", DefaultLocale},
+		{"es", "es"},
+		{"es-MX", "es"},
+		{"ES-mx", "es"},
+		{"fr, es;q=0.8, en;q=0.5", "es"},
+		{"en;q=0.4, es;q=0.9", "es"},
+		{"es;q=0, en", "en"},
+		{"es;q=0", DefaultLocale},
+		{"es;q=abc, en;q=0.1", "en"},
+		{"de, fr", DefaultLocale},
+		{"*", DefaultLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			if got := MatchLocale(tt.acceptLanguage); got != tt.want {
+				t.Errorf("MatchLocale(%q) = %q, want %q", tt.acceptLanguage, got, tt.want)
+			}
+		})
+	}
+}
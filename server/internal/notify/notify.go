@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/mail"
+	"github.com/Aaditya-23/server/internal/utils"
+)
+
+// mailer sends the emails that are not part of signing in, those go through
+// the auth package.
+var mailer mail.Mailer
+
+func SetMailer(m mail.Mailer) {
+	mailer = m
+}
+
+func Welcome(ctx context.Context, email, locale string) error {
+	return send(ctx, email, locale, mail.TemplateWelcome, mail.WelcomeData{
+		ShopURL: utils.FrontendURL("/products"),
+	})
+}
+
+func OrderConfirmation(ctx context.Context, email, locale string, order database.Order) error {
+	data := mail.OrderConfirmationData{
+		OrderId:  order.Id,
+		Items:    make([]mail.OrderLine, len(order.Items)),
+		Subtotal: order.Subtotal,
+		Discount: order.Discount,
+		Total:    order.Total,
+		OrderURL: utils.FrontendURL("/profile"),
+	}
+
+	for i, item := range order.Items {
+		data.Items[i] = mail.OrderLine{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+		}
+		if item.Variant != nil {
+			data.Items[i].Variant = describeVariant(*item.Variant)
+		}
+	}
+
+	return send(ctx, email, locale, mail.TemplateOrderConfirmation, data)
+}
+
+func send(ctx context.Context, email, locale, template string, data any) error {
+	if mailer == nil {
+		return errors.New("mailer is not set")
+	}
+
+	msg, err := mail.Render(template, locale, data)
+	if err != nil {
+		return err
+	}
+
+	msg.To = []string{email}
+	return mailer.Send(ctx, msg)
+}
+
+// describeVariant lists the options of a variant sorted by name, e.g.
+// "color: red, size: M".
+func describeVariant(variant map[string]string) string {
+	options := make([]string, 0, len(variant))
+	for name, value := range variant {
+		options = append(options, name+": "+value)
+	}
+	sort.Strings(options)
+
+	return strings.Join(options, ", ")
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/mail"
+)
+
+func TestWelcome(t *testing.T) {
+	t.Setenv("FRONTEND_URL", "https://shop.example.com")
+
+	tests := []struct {
+		locale  string
+		subject string
+	}{
+		{"en", "Welcome to the shop"},
+		{"es", "Te damos la bienvenida a la tienda"},
+		{"de", "Welcome to the shop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			memory := mail.NewMemory()
+			SetMailer(memory)
+
+			if err := Welcome(context.Background(), "a@example.com", tt.locale); err != nil {
+				t.Fatalf("Welcome() error = %v", err)
+			}
+
+			messages := memory.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(messages))
+			}
+
+			msg := messages[0]
+			if len(msg.To) != 1 || msg.To[0] != "a@example.com" {
+				t.Errorf("To = %q, want %q", msg.To, []string{"a@example.com"})
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if !strings.Contains(msg.Text, "https://shop.example.com/products") {
+				t.Errorf("Text = %q, want it to link to the products", msg.Text)
+			}
+		})
+	}
+}
+
+func TestOrderConfirmation(t *testing.T) {
+	memory := mail.NewMemory()
+	SetMailer(memory)
+
+	variant := map[string]string{"size": "M", "color": "red"}
+	order := database.Order{
+		Id:       42,
+		Subtotal: 25,
+		Total:    25,
+		Items: []database.OrderItem{
+			{Name: "Shirt", Variant: &variant, Price: 10, Quantity: 2},
+			{Name: "Mug", Price: 5, Quantity: 1},
+		},
+	}
+
+	if err := OrderConfirmation(context.Background(), "a@example.com", "en", order); err != nil {
+		t.Fatalf("OrderConfirmation() error = %v", err)
+	}
+
+	messages := memory.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(messages))
+	}
+
+	for _, want := range []string{"- Shirt (color: red, size: M) x 2, $10.00 each", "- Mug x 1, $5.00 each", "Total: $25.00"} {
+		if !strings.Contains(messages[0].Text, want) {
+			t.Errorf("Text = %q, want it to contain %q", messages[0].Text, want)
+		}
+	}
+}
+
+func TestDescribeVariant(t *testing.T) {
+	tests := []struct {
+		name    string
+		variant map[string]string
+		want    string
+	}{
+		{"no options", map[string]string{}, ""},
+		{"one option", map[string]string{"color": "red"}, "color: red"},
+		{"sorted by name", map[string]string{"size": "M", "color": "red"}, "color: red, size: M"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeVariant(tt.variant); got != tt.want {
+				t.Errorf("describeVariant() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxResponseSize bounds what is read from a provider.
+const maxResponseSize = 1 << 20
+
+type endpoint struct {
+	AuthURL  string
+	TokenURL string
+}
+
+func authCodeURL(endpoint endpoint, config Config, state, codeChallenge string) (string, error) {
+	authURL, err := url.Parse(endpoint.AuthURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", config.ClientId)
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	if config.RedirectURL != "" {
+		query.Set("redirect_uri", config.RedirectURL)
+	}
+	if len(config.Scopes) > 0 {
+		query.Set("scope", strings.Join(config.Scopes, " "))
+	}
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// exchange redeems the code at the token endpoint. The client secret goes in
+// the form body, never in the url where it would end up in logs.
+func exchange(ctx context.Context, client *http.Client, endpoint endpoint, config Config, code, codeVerifier string) (Token, error) {
+	var token Token
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", config.ClientId)
+	form.Set("client_secret", config.ClientSecret)
+	if config.RedirectURL != "" {
+		form.Set("redirect_uri", config.RedirectURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	// GitHub reports a bad code with a 200, the error field covers both
+	if err := doJSON(client, req, &body); err != nil && body.Error == "" {
+		return token, err
+	}
+	if body.Error != "" {
+		return token, fmt.Errorf("token exchange failed: %s", strings.TrimSpace(body.Error+" "+body.ErrorDescription))
+	}
+	if body.AccessToken == "" {
+		return token, errors.New("token exchange returned no access token")
+	}
+
+	token.AccessToken = body.AccessToken
+	token.TokenType = body.TokenType
+	token.IDToken = body.IDToken
+
+	return token, nil
+}
+
+// getJSON fetches url with the access token and decodes the response into v.
+func getJSON(ctx context.Context, client *http.Client, url string, token Token, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	}
+
+	return doJSON(client, req, v)
+}
+
+// doJSON decodes the response into v even when the status is not 2xx, so that
+// error bodies can be read, and then reports the status as an error.
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	decodeErr := json.Unmarshal(body, v)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %s", req.Method, req.URL.Redacted(), res.Status)
+	}
+
+	return decodeErr
+}
@@ -0,0 +1,51 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/Aaditya-23/server/internal/utils"
+)
+
+const (
+	stateSize = 32
+	// verifierSize gives a 43 character verifier, the least RFC 7636 allows.
+	verifierSize = 32
+)
+
+// Flow is one sign in attempt. The state ties the callback to the browser that
+// started it and the verifier proves to the provider that the code is redeemed
+// by whoever asked for it (PKCE).
+type Flow struct {
+	State    string
+	Verifier string
+}
+
+func NewFlow() (Flow, error) {
+	var (
+		flow Flow
+		err  error
+	)
+
+	flow.State, err = utils.RandomToken(stateSize)
+	if err != nil {
+		return flow, err
+	}
+
+	flow.Verifier, err = utils.RandomToken(verifierSize)
+
+	return flow, err
+}
+
+// Challenge is the S256 code challenge for the verifier.
+func (f Flow) Challenge() string {
+	hash := sha256.Sum256([]byte(f.Verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// MatchesState compares in constant time so the state cannot be guessed a
+// byte at a time.
+func (f Flow) MatchesState(state string) bool {
+	return f.State != "" && subtle.ConstantTimeCompare([]byte(f.State), []byte(state)) == 1
+}
@@ -0,0 +1,40 @@
+package oauth
+
+import "testing"
+
+func TestFlowChallenge(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		want     string
+	}{
+		// RFC 7636, appendix B
+		{"rfc 7636 example", "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Flow{Verifier: tt.verifier}).Challenge(); got != tt.want {
+				t.Errorf("Challenge() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFlow(t *testing.T) {
+	flow, err := NewFlow()
+	if err != nil {
+		t.Fatalf("NewFlow() error = %v", err)
+	}
+
+	// RFC 7636 wants a verifier of 43 to 128 characters
+	if n := len(flow.Verifier); n < 43 || n > 128 {
+		t.Errorf("verifier has %d characters, want 43 to 128", n)
+	}
+	if !flow.MatchesState(flow.State) {
+		t.Error("MatchesState() = false for the flow's own state")
+	}
+	if flow.MatchesState("") || flow.MatchesState(flow.State+"x") {
+		t.Error("MatchesState() = true for another state")
+	}
+}
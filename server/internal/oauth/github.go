@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+type GitHub struct {
+	config Config
+	client *http.Client
+}
+
+var githubEndpoint = endpoint{
+	AuthURL:  "https://github.com/login/oauth/authorize",
+	TokenURL: "https://github.com/login/oauth/access_token",
+}
+
+const githubAPI = "https://api.github.com"
+
+func NewGitHub(config Config, client *http.Client) *GitHub {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"read:user", "user:email"}
+	}
+	if client == nil {
+		client = NewHTTPClient(defaultTimeout)
+	}
+
+	return &GitHub{config: config, client: client}
+}
+
+func (g *GitHub) Name() string {
+	return "github"
+}
+
+func (g *GitHub) AuthURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	return authCodeURL(githubEndpoint, g.config, state, codeChallenge)
+}
+
+func (g *GitHub) Exchange(ctx context.Context, code, codeVerifier string) (Token, error) {
+	return exchange(ctx, g.client, githubEndpoint, g.config, code, codeVerifier)
+}
+
+// User only takes the primary email and only when GitHub has verified it, the
+// public profile email is whatever the user typed in.
+func (g *GitHub) User(ctx context.Context, token Token) (User, error) {
+	var user User
+
+	var profile struct {
+		Id    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, g.client, githubAPI+"/user", token, &profile); err != nil {
+		return user, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, g.client, githubAPI+"/user/emails", token, &emails); err != nil {
+		return user, err
+	}
+
+	user.Subject = strconv.FormatInt(profile.Id, 10)
+	user.Name = profile.Name
+	if user.Name == "" {
+		user.Name = profile.Login
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			user.Email = email.Email
+			user.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,112 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown login provider")
+	// ErrNoVerifiedEmail is returned when the provider has no verified email
+	// for the user, an unverified one could belong to anybody.
+	ErrNoVerifiedEmail = errors.New("login provider has no verified email")
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Provider signs users in with the authorization code flow. Every request it
+// makes goes through the http.Client it was built with.
+type Provider interface {
+	Name() string
+	// AuthURL is where the user is sent to sign in, the provider sends them
+	// back to the redirect url with a code and the state.
+	AuthURL(ctx context.Context, state, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (Token, error)
+	User(ctx context.Context, token Token) (User, error)
+}
+
+type Config struct {
+	ClientId     string
+	ClientSecret string
+	// RedirectURL is left out of the requests when it is empty, the provider
+	// then uses the one registered with it.
+	RedirectURL string
+	Scopes      []string
+}
+
+type Token struct {
+	AccessToken string
+	TokenType   string
+	IDToken     string
+}
+
+// User is the account at the provider. Subject is the provider's stable id
+// for it, unlike the email it never changes.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+var providers = map[string]Provider{}
+
+func Register(provider Provider) {
+	providers[provider.Name()] = provider
+}
+
+func Lookup(name string) (Provider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return provider, nil
+}
+
+// Init registers every provider that has a client id set. Outbound calls time
+// out after OAUTH_TIMEOUT, a Go duration.
+func Init() {
+	client := NewHTTPClient(defaultTimeout)
+	if timeout, err := time.ParseDuration(os.Getenv("OAUTH_TIMEOUT")); err == nil && timeout > 0 {
+		client = NewHTTPClient(timeout)
+	}
+
+	if config, ok := configFromEnv("GITHUB"); ok {
+		Register(NewGitHub(config, client))
+	}
+
+	if config, ok := configFromEnv("GOOGLE"); ok {
+		Register(NewGoogle(config, client))
+	}
+
+	if config, ok := configFromEnv("OIDC"); ok {
+		name := os.Getenv("OIDC_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+
+		Register(NewOIDC(name, os.Getenv("OIDC_ISSUER"), config, client))
+	}
+}
+
+// configFromEnv reads <PREFIX>_CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and
+// the space separated _SCOPES.
+func configFromEnv(prefix string) (Config, bool) {
+	config := Config{
+		ClientId:     os.Getenv(prefix + "_CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv(prefix + "_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv(prefix + "_SCOPES")),
+	}
+
+	return config, config.ClientId != ""
+}
+
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout}
+}
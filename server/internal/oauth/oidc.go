@@ -0,0 +1,150 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// OIDC is any OpenID Connect provider, its endpoints are read from the
+// discovery document of the issuer on first use.
+type OIDC struct {
+	name   string
+	issuer string
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+func NewOIDC(name, issuer string, config Config, client *http.Client) *OIDC {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = NewHTTPClient(defaultTimeout)
+	}
+
+	return &OIDC{
+		name:   name,
+		issuer: strings.TrimRight(issuer, "/"),
+		config: config,
+		client: client,
+	}
+}
+
+func NewGoogle(config Config, client *http.Client) *OIDC {
+	return NewOIDC("google", "https://accounts.google.com", config, client)
+}
+
+func (o *OIDC) Name() string {
+	return o.name
+}
+
+func (o *OIDC) AuthURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	discovery, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return authCodeURL(discovery.endpoint(), o.config, state, codeChallenge)
+}
+
+func (o *OIDC) Exchange(ctx context.Context, code, codeVerifier string) (Token, error) {
+	discovery, err := o.discover(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return exchange(ctx, o.client, discovery.endpoint(), o.config, code, codeVerifier)
+}
+
+// User reads the claims from the userinfo endpoint. The access token came
+// straight from the token endpoint over TLS, so the id token does not have to
+// be verified for this.
+func (o *OIDC) User(ctx context.Context, token Token) (User, error) {
+	var user User
+
+	discovery, err := o.discover(ctx)
+	if err != nil {
+		return user, err
+	}
+
+	var claims struct {
+		Subject       string   `json:"sub"`
+		Email         string   `json:"email"`
+		EmailVerified jsonBool `json:"email_verified"`
+		Name          string   `json:"name"`
+	}
+	if err := getJSON(ctx, o.client, discovery.UserinfoEndpoint, token, &claims); err != nil {
+		return user, err
+	}
+
+	if claims.Subject == "" {
+		return user, errors.New("userinfo has no subject")
+	}
+
+	user.Subject = claims.Subject
+	user.Email = claims.Email
+	user.EmailVerified = bool(claims.EmailVerified)
+	user.Name = claims.Name
+
+	return user, nil
+}
+
+// discover caches the document once it was fetched, a failed fetch is retried
+// on the next call.
+func (o *OIDC) discover(ctx context.Context) (*discovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	var document discovery
+	if err := getJSON(ctx, o.client, o.issuer+"/.well-known/openid-configuration", Token{}, &document); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimRight(document.Issuer, "/") != o.issuer {
+		return nil, errors.New("discovery document is for issuer " + document.Issuer)
+	}
+	if document.AuthorizationEndpoint == "" || document.TokenEndpoint == "" || document.UserinfoEndpoint == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	o.discovery = &document
+	return o.discovery, nil
+}
+
+func (d *discovery) endpoint() endpoint {
+	return endpoint{AuthURL: d.AuthorizationEndpoint, TokenURL: d.TokenEndpoint}
+}
+
+// jsonBool also accepts "true" and "false" as strings, which some providers
+// send for email_verified.
+type jsonBool bool
+
+func (b *jsonBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null":
+		*b = false
+	default:
+		return errors.New("invalid boolean " + string(data))
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/mail"
+)
+
+// Mailer queues messages in the email_outbox table instead of sending them,
+// the worker started with StartWorker delivers them. Send only fails when the
+// database does, never because of the mail server.
+type Mailer struct{}
+
+func (Mailer) Send(ctx context.Context, msg mail.Message) error {
+	_, err := database.EnqueueEmail(database.NewEmail{
+		From:     msg.From,
+		To:       msg.To,
+		Subject:  msg.Subject,
+		Text:     msg.Text,
+		HTML:     msg.HTML,
+		ValidFor: msg.ValidFor,
+	})
+	if err != nil {
+		return err
+	}
+
+	wakeWorker()
+	return nil
+}
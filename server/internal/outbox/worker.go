@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/Aaditya-23/server/internal/database"
+	"github.com/Aaditya-23/server/internal/mail"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	batchSize    = 10
+	// lease is how long a claimed email is held, it has to outlast a send.
+	lease = 2 * time.Minute
+
+	// the n-th retry waits baseBackoff * 2^(n-1), at most maxBackoff
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+	// maxAttempts gives an email about an hour of retries before it goes dead
+	maxAttempts = 8
+
+	sweepInterval = time.Hour
+	sentRetention = 30 * 24 * time.Hour
+	// dead emails are only kept long enough to look into their last error
+	deadRetention = 7 * 24 * time.Hour
+)
+
+// wake lets Mailer.Send start a delivery right away instead of at the next
+// poll.
+var wake = make(chan struct{}, 1)
+
+func wakeWorker() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartWorker delivers the queued emails through mailer in the background and
+// purges the old sent and dead ones.
+func StartWorker(mailer mail.Mailer) {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		lastSweep := time.Time{}
+		for {
+			deliverDue(mailer)
+
+			if time.Since(lastSweep) >= sweepInterval {
+				sweep()
+				lastSweep = time.Now()
+			}
+
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// deliverDue keeps claiming batches until no email is due. Emails that
+// expired while waiting go dead first rather than being sent late.
+func deliverDue(mailer mail.Mailer) {
+	expired, err := database.ExpireEmails()
+	if err != nil {
+		println("error occured while expiring queued emails", err.Error())
+	} else if expired > 0 {
+		println("expired", expired, "queued emails")
+	}
+
+	for {
+		emails, err := database.ClaimDueEmails(batchSize, lease)
+		if err != nil {
+			println("error occured while claiming queued emails", err.Error())
+			return
+		}
+
+		for _, email := range emails {
+			deliver(mailer, email)
+		}
+
+		if len(emails) < batchSize {
+			return
+		}
+	}
+}
+
+func deliver(mailer mail.Mailer, email database.OutboxEmail) {
+	ctx, cancel := context.WithTimeout(context.Background(), lease)
+	defer cancel()
+
+	sendErr := mailer.Send(ctx, mail.Message{
+		From:    email.From,
+		To:      email.To,
+		Subject: email.Subject,
+		Text:    email.Text,
+		HTML:    email.HTML,
+	})
+
+	var err error
+	if sendErr == nil {
+		err = database.MarkEmailSent(email.Id)
+	} else {
+		dead := email.Attempts >= maxAttempts
+		err = database.MarkEmailFailed(email.Id, sendErr.Error(), backoff(email.Attempts), dead)
+
+		if dead {
+			println("email", email.Id, "is dead after", email.Attempts, "attempts,", sendErr.Error())
+		}
+	}
+
+	// the lease runs out and the email is claimed again
+	if err != nil {
+		println("error occured while recording email delivery", err.Error())
+	}
+}
+
+func backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, maxBackoff)
+}
+
+func sweep() {
+	sweepStatus(database.EmailSent, sentRetention)
+	sweepStatus(database.EmailDead, deadRetention)
+}
+
+func sweepStatus(status string, retention time.Duration) {
+	deleted, err := database.DeleteOldEmails(status, retention)
+	if err != nil {
+		println("error occured while purging old", status, "emails", err.Error())
+		return
+	}
+
+	if deleted > 0 {
+		println("purged", deleted, "old", status, "emails")
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket is back to its limit, it can be dropped then
+	full time.Time
+}
+
+// Memory is a token bucket store kept in the memory of the process.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*bucket{}}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	capacity := float64(limit.Requests)
+	// tokens refilled per second
+	rate := capacity / limit.Per.Seconds()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		retryAfter := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, retryAfter, nil
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((capacity - b.tokens) / rate * float64(time.Second)))
+
+	return true, 0, nil
+}
+
+// sweep drops the buckets that refilled completely, they are no different
+// from a new one.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+
+	m.lastSweep = now
+}
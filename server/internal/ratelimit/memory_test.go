@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryTake(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit Limit
+		takes int
+		// allowed is how many of the takes go through
+		allowed int
+		// the retry after of the first refused take is within these
+		minRetry, maxRetry time.Duration
+	}{
+		{"under the limit", Limit{Requests: 3, Per: time.Hour}, 2, 2, 0, 0},
+		{"at the limit", Limit{Requests: 3, Per: time.Hour}, 3, 3, 0, 0},
+		{"over the limit", Limit{Requests: 3, Per: time.Hour}, 5, 3, 19 * time.Minute, 20 * time.Minute},
+		{"single request", Limit{Requests: 1, Per: time.Minute}, 2, 1, 59 * time.Second, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemory()
+
+			allowed := 0
+			var retryAfter time.Duration
+			for range tt.takes {
+				ok, retry, err := store.Take(context.Background(), "key", tt.limit)
+				if err != nil {
+					t.Fatalf("Take() error = %v", err)
+				}
+
+				if ok {
+					allowed++
+				} else if retryAfter == 0 {
+					retryAfter = retry
+				}
+			}
+
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d takes, want %d", allowed, tt.allowed)
+			}
+			if retryAfter < tt.minRetry || retryAfter > tt.maxRetry {
+				t.Errorf("retry after = %v, want between %v and %v", retryAfter, tt.minRetry, tt.maxRetry)
+			}
+		})
+	}
+}
+
+func TestMemoryTakeKeysAreSeparate(t *testing.T) {
+	store := NewMemory()
+	limit := Limit{Requests: 1, Per: time.Hour}
+
+	for _, key := range []string{"a", "b"} {
+		if ok, _, _ := store.Take(context.Background(), key, limit); !ok {
+			t.Errorf("first take of %q refused", key)
+		}
+	}
+
+	if ok, _, _ := store.Take(context.Background(), "a", limit); ok {
+		t.Error("second take of \"a\" allowed")
+	}
+}
+
+func TestMemoryTakeRefills(t *testing.T) {
+	store := NewMemory()
+	limit := Limit{Requests: 1, Per: 50 * time.Millisecond}
+
+	if ok, _, _ := store.Take(context.Background(), "key", limit); !ok {
+		t.Fatal("first take refused")
+	}
+	if ok, _, _ := store.Take(context.Background(), "key", limit); ok {
+		t.Fatal("take on an empty bucket allowed")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if ok, _, _ := store.Take(context.Background(), "key", limit); !ok {
+		t.Error("take after the refill refused")
+	}
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests requests every Per, refilled continuously so that a
+// burst of Requests is possible after a quiet period.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit reads a limit written as <requests>/<duration>, e.g. 5/15m.
+func ParseLimit(value string) (Limit, error) {
+	requests, per, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, errors.New("limit must look like 5/15m")
+	}
+
+	var (
+		limit Limit
+		err   error
+	)
+
+	limit.Requests, err = strconv.Atoi(requests)
+	if err != nil || limit.Requests < 1 {
+		return limit, errors.New("limit must allow at least one request")
+	}
+
+	limit.Per, err = time.ParseDuration(per)
+	if err != nil || limit.Per <= 0 {
+		return limit, errors.New("limit must have a positive duration")
+	}
+
+	return limit, nil
+}
+
+// Store keeps the buckets. Memory works for a single instance, a store shared
+// between instances can be plugged in with SetStore.
+type Store interface {
+	// Take spends one request from the bucket of key. When none is left it
+	// reports how long until the next one.
+	Take(ctx context.Context, key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
+
+var store Store = NewMemory()
+
+func SetStore(s Store) {
+	store = s
+}
+
+func Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	return store.Take(ctx, key, limit)
+}
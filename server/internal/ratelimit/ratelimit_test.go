@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{"5/15m", Limit{Requests: 5, Per: 15 * time.Minute}, false},
+		{"1/1s", Limit{Requests: 1, Per: time.Second}, false},
+		{"100/1h30m", Limit{Requests: 100, Per: 90 * time.Minute}, false},
+		{"", Limit{}, true},
+		{"5", Limit{}, true},
+		{"0/1m", Limit{}, true},
+		{"-1/1m", Limit{}, true},
+		{"x/1m", Limit{}, true},
+		{"5/", Limit{}, true},
+		{"5/15", Limit{}, true},
+		{"5/0s", Limit{}, true},
+		{"5/-1m", Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
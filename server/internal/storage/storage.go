@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage is where uploaded files are kept. Keys are flat names without any
+// slashes, backends are free to lay them out however they like.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var store Storage
+
+// Init picks the backend from STORAGE_DRIVER, "local" when it is not set.
+func Init() {
+	var err error
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		store, err = NewLocal(os.Getenv("STORAGE_DIR"))
+	case "s3":
+		store, err = NewS3(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyId:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		err = errors.New("unknown STORAGE_DRIVER " + driver)
+	}
+
+	if err != nil {
+		println("Failed to set up storage,", err.Error())
+		os.Exit(1)
+	}
+}
+
+func Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return store.Put(ctx, key, data, contentType)
+}
+
+func Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return store.Get(ctx, key)
+}
+
+func Delete(ctx context.Context, key string) error {
+	return store.Delete(ctx, key)
+}
@@ -0,0 +1,26 @@
+package utils
+
+import "encoding/json"
+
+// Nullable tells a JSON field that was left out apart from one sent as null.
+// Value is nil in both cases, Set is only true when the field was sent.
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	n.Value = &value
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// RandomToken reads size bytes from crypto/rand and encodes them as unpadded
+// base64url, safe to put in links and cookies.
+func RandomToken(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+const defaultFrontendURL = "https://react-go-rouge.vercel.app"
+
+// FrontendURL joins path to the base url of the react client, set with
+// FRONTEND_URL so that every deployment links to its own client.
+func FrontendURL(path string) string {
+	base := os.Getenv("FRONTEND_URL")
+	if base == "" {
+		base = defaultFrontendURL
+	}
+
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The Min, Max and IsOneOf rules of the validator read the value as soon as
+// they are added, so they panic on optional fields that were not sent. These
+// refinements do the same checks but only run when the field is present.
+
+func AtLeast[T int64 | float64](name string, min T) func(T) error {
+	return func(value T) error {
+		if value < min {
+			return fmt.Errorf("%s must be atleast %v", name, min)
+		}
+
+		return nil
+	}
+}
+
+func MinLength(name string, length int) func(string) error {
+	return func(value string) error {
+		if len(value) < length {
+			return fmt.Errorf("%s should have atleast %d characters", name, length)
+		}
+
+		return nil
+	}
+}
+
+func MaxItems[T any](name string, length int) func([]T) error {
+	return func(value []T) error {
+		if len(value) > length {
+			return fmt.Errorf("%s must have atmost %d items", name, length)
+		}
+
+		return nil
+	}
+}
+
+func OneOf(name string, values []string) func(string) error {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("%s can only be %s", name, strings.Join(values, ", "))
+		}
+
+		return nil
+	}
+}
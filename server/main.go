@@ -4,14 +4,26 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/Aaditya-23/server/internal/auth"
 	"github.com/Aaditya-23/server/internal/database"
 	"github.com/Aaditya-23/server/internal/handler"
+	"github.com/Aaditya-23/server/internal/images"
+	"github.com/Aaditya-23/server/internal/mail"
+	"github.com/Aaditya-23/server/internal/notify"
+	"github.com/Aaditya-23/server/internal/oauth"
+	"github.com/Aaditya-23/server/internal/outbox"
+	"github.com/Aaditya-23/server/internal/storage"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
 func main() {
 	godotenv.Load()
+
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		println("PORT env is not set")
@@ -21,10 +33,33 @@ func main() {
 	database.Init()
 	defer database.Close()
 
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		if err := database.BootstrapAdmin(adminEmail); err != nil {
+			println("Failed to bootstrap admin,", err.Error())
+			return
+		}
+	}
+
+	storage.Init()
+	oauth.Init()
+
+	mailer, err := mail.FromEnv()
+	if err != nil {
+		println("Failed to set up the mailer,", err.Error())
+		return
+	}
+	// requests only queue emails, the worker is the one talking to the server
+	outbox.StartWorker(mailer)
+	auth.SetMailer(outbox.Mailer{})
+	notify.SetMailer(outbox.Mailer{})
+
+	auth.StartSweeper()
+	images.StartSweeper()
+
 	r := handler.Mount()
 
 	println("Starting the server on port " + port)
-	err := http.ListenAndServe(":"+port, r)
+	err = http.ListenAndServe(":"+port, r)
 	if err != nil {
 		println("Failed to start the server")
 	}